@@ -18,7 +18,7 @@ type mergeRequestApproverService struct {
 
 /* approveHandler approves a merge request. */
 func (a mergeRequestApproverService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	_, res, err := a.client.ApproveMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, nil, nil)
+	_, res, err := a.client.ApproveMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), nil, nil)
 
 	if err != nil {
 		handleError(w, err, "Could not approve merge request", http.StatusInternalServerError)
@@ -32,9 +32,9 @@ func (a assigneesService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, &gitlab.UpdateMergeRequestOptions{
+	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.UpdateMergeRequestOptions{
 		AssigneeIDs: &assigneeUpdateRequest.Ids,
-	})
+	}, withRetries())
 
 	if err != nil {
 		handleError(w, err, "Could not modify merge request assignees", http.StatusInternalServerError)
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const bulkResolveConcurrency = 4
+
+/*
+BulkResolveRequest resolves or unresolves many discussions at once, optionally replying to each with the same note.
+Without Resolved the discussions are only replied to and keep their resolved state.
+*/
+type BulkResolveRequest struct {
+	DiscussionIDs []string `json:"discussion_ids" validate:"required,min=1,max=100,dive,required"`
+	Reply         string   `json:"reply" validate:"required_without=Resolved"`
+	Resolved      *bool    `json:"resolved"`
+}
+
+/* BulkResolveResult is the outcome for one discussion. A reply may have been posted even when resolving failed. */
+type BulkResolveResult struct {
+	DiscussionID string       `json:"discussion_id"`
+	Success      bool         `json:"success"`
+	Note         *gitlab.Note `json:"note,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+type BulkResolveResponse struct {
+	SuccessResponse
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []BulkResolveResult `json:"results"`
+}
+
+type BulkDiscussionResolver interface {
+	AddMergeRequestDiscussionNote(interface{}, int, string, *gitlab.AddMergeRequestDiscussionNoteOptions, ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error)
+	DiscussionResolver
+}
+
+type bulkResolveService struct {
+	data
+	client BulkDiscussionResolver
+}
+
+/*
+bulkResolveHandler replies to and resolves (or unresolves) a batch of discussions, with a bounded number of
+discussions in flight. A failure on one discussion does not stop the others, so the response always reports the
+outcome of every discussion, in the order they were given.
+*/
+func (a bulkResolveService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := r.Context().Value(payload("payload")).(*BulkResolveRequest)
+	mergeId := a.mergeId(r)
+
+	friendlyName, doneName := "reply to", "replied to"
+	if payload.Resolved != nil {
+		friendlyName, doneName = "unresolve", "unresolved"
+		if *payload.Resolved {
+			friendlyName, doneName = "resolve", "resolved"
+		}
+	}
+
+	/* Resolving a discussion twice would post the reply twice */
+	var discussionIDs []string
+	for _, id := range payload.DiscussionIDs {
+		if !Contains(discussionIDs, id) {
+			discussionIDs = append(discussionIDs, id)
+		}
+	}
+
+	results := make([]BulkResolveResult, len(discussionIDs))
+	sem := make(chan struct{}, bulkResolveConcurrency)
+	var wg sync.WaitGroup
+
+	for i, id := range discussionIDs {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = a.resolveOne(mergeId, id, payload.Reply, payload.Resolved)
+		}(i, id)
+	}
+
+	wg.Wait()
+
+	response := BulkResolveResponse{Results: results}
+	for _, result := range results {
+		if result.Success {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	response.Message = fmt.Sprintf("Discussions %s", doneName)
+	if response.Failed > 0 {
+		response.Message = fmt.Sprintf("Could not %s %d of %d discussions", friendlyName, response.Failed, len(results))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/* resolveOne posts the reply, if there is one, and only then changes the discussion's resolved state, if asked to */
+func (a bulkResolveService) resolveOne(mergeId int, discussionID string, reply string, resolved *bool) BulkResolveResult {
+	result := BulkResolveResult{DiscussionID: discussionID}
+
+	if reply != "" {
+		now := time.Now()
+		options := gitlab.AddMergeRequestDiscussionNoteOptions{
+			Body:      gitlab.Ptr(reply),
+			CreatedAt: &now,
+		}
+		note, res, err := a.client.AddMergeRequestDiscussionNote(a.projectInfo.ProjectId, mergeId, discussionID, &options)
+		if err == nil && res.StatusCode >= 300 {
+			err = fmt.Errorf("returned status %d", res.StatusCode)
+		}
+		if err != nil {
+			result.Error = fmt.Sprintf("could not reply: %s", err)
+			return result
+		}
+		result.Note = note
+	}
+
+	if resolved == nil {
+		result.Success = true
+		return result
+	}
+
+	_, res, err := a.client.ResolveMergeRequestDiscussion(
+		a.projectInfo.ProjectId,
+		mergeId,
+		discussionID,
+		&gitlab.ResolveMergeRequestDiscussionOptions{Resolved: resolved},
+		withRetries(),
+	)
+	if err == nil && res.StatusCode >= 300 {
+		err = fmt.Errorf("returned status %d", res.StatusCode)
+	}
+	if err != nil {
+		action := "unresolve"
+		if *resolved {
+			action = "resolve"
+		}
+		result.Error = fmt.Sprintf("could not %s: %s", action, err)
+		return result
+	}
+
+	result.Success = true
+	return result
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeBulkDiscussionResolver struct {
+	testBase
+	failReply   string
+	failResolve string
+	replies     *int32
+	resolves    *int32
+	inFlight    *int32
+	maxInFlight *int32
+}
+
+func (f fakeBulkDiscussionResolver) AddMergeRequestDiscussionNote(pid interface{}, mergeRequest int, discussion string, opt *gitlab.AddMergeRequestDiscussionNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if discussion == f.failReply {
+		return nil, nil, errors.New("reply failed")
+	}
+	if f.replies != nil {
+		atomic.AddInt32(f.replies, 1)
+	}
+	return &gitlab.Note{Body: *opt.Body}, resp, err
+}
+
+func (f fakeBulkDiscussionResolver) ResolveMergeRequestDiscussion(pid interface{}, mergeRequest int, discussion string, opt *gitlab.ResolveMergeRequestDiscussionOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Discussion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if f.resolves != nil {
+		atomic.AddInt32(f.resolves, 1)
+	}
+
+	if f.inFlight != nil {
+		current := atomic.AddInt32(f.inFlight, 1)
+		defer atomic.AddInt32(f.inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(f.maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt32(f.maxInFlight, seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if discussion == f.failResolve {
+		return nil, makeResponse(http.StatusForbidden), nil
+	}
+	return &gitlab.Discussion{ID: discussion}, resp, err
+}
+
+func getBulkResolveData(t *testing.T, svc http.Handler, request *http.Request) BulkResolveResponse {
+	res := httptest.NewRecorder()
+	svc.ServeHTTP(res, request)
+
+	var data BulkResolveResponse
+	err := json.Unmarshal(res.Body.Bytes(), &data)
+	if err != nil {
+		t.Error(err)
+	}
+	return data
+}
+
+func TestBulkResolveHandler(t *testing.T) {
+	bulkResolveService := func(client BulkDiscussionResolver) http.HandlerFunc {
+		return middleware(
+			bulkResolveService{testProjectData, client},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPut: newPayload[BulkResolveRequest]}),
+			withMethodCheck(http.MethodPut),
+		)
+	}
+
+	t.Run("Replies to and resolves every discussion", func(t *testing.T) {
+		var replies int32
+		request := makeRequest(t, http.MethodPut, "/mr/discussions/resolve/bulk", BulkResolveRequest{DiscussionIDs: []string{"a", "b", "a"}, Reply: "Fixed in the rework", Resolved: gitlab.Ptr(true)})
+		data := getBulkResolveData(t, bulkResolveService(fakeBulkDiscussionResolver{replies: &replies}), request)
+		assert(t, data.Message, "Discussions resolved")
+		assert(t, data.Succeeded, 2)
+		assert(t, data.Failed, 0)
+		assert(t, replies, int32(2))
+		assert(t, data.Results[0].DiscussionID, "a")
+		assert(t, data.Results[1].Note.Body, "Fixed in the rework")
+	})
+	t.Run("Reports failures per discussion", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPut, "/mr/discussions/resolve/bulk", BulkResolveRequest{DiscussionIDs: []string{"a", "b", "c"}, Reply: "Done", Resolved: gitlab.Ptr(false)})
+		data := getBulkResolveData(t, bulkResolveService(fakeBulkDiscussionResolver{failReply: "a", failResolve: "b"}), request)
+		assert(t, data.Message, "Could not unresolve 2 of 3 discussions")
+		assert(t, data.Succeeded, 1)
+		assert(t, data.Results[0].Success, false)
+		assert(t, data.Results[0].Error, "could not reply: reply failed")
+		assert(t, data.Results[1].Success, false)
+		assert(t, data.Results[1].Note != nil, true)
+		assert(t, data.Results[1].Error, "could not unresolve: returned status 403")
+		assert(t, data.Results[2].Success, true)
+	})
+	t.Run("Only replies when resolved is left out", func(t *testing.T) {
+		var replies, resolves int32
+		request := makeRequest(t, http.MethodPut, "/mr/discussions/resolve/bulk", BulkResolveRequest{DiscussionIDs: []string{"a", "b"}, Reply: "Bump"})
+		data := getBulkResolveData(t, bulkResolveService(fakeBulkDiscussionResolver{replies: &replies, resolves: &resolves}), request)
+		assert(t, data.Message, "Discussions replied to")
+		assert(t, data.Succeeded, 2)
+		assert(t, replies, int32(2))
+		assert(t, resolves, int32(0))
+	})
+	t.Run("Requires a reply when resolved is left out", func(t *testing.T) {
+		var resolves int32
+		request := makeRequest(t, http.MethodPut, "/mr/discussions/resolve/bulk", BulkResolveRequest{DiscussionIDs: []string{"a"}})
+		data, status := getFailData(t, bulkResolveService(fakeBulkDiscussionResolver{resolves: &resolves}), request)
+		assert(t, data.Message, "Invalid payload")
+		assert(t, status, http.StatusBadRequest)
+		assert(t, resolves, int32(0))
+	})
+	t.Run("Limits how many discussions are in flight", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		ids := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+		request := makeRequest(t, http.MethodPut, "/mr/discussions/resolve/bulk", BulkResolveRequest{DiscussionIDs: ids, Resolved: gitlab.Ptr(true)})
+		data := getBulkResolveData(t, bulkResolveService(fakeBulkDiscussionResolver{inFlight: &inFlight, maxInFlight: &maxInFlight}), request)
+		assert(t, data.Succeeded, 10)
+		assert(t, maxInFlight <= bulkResolveConcurrency, true)
+	})
+	t.Run("Requires discussion IDs", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPut, "/mr/discussions/resolve/bulk", BulkResolveRequest{DiscussionIDs: []string{}})
+		data, status := getFailData(t, bulkResolveService(fakeBulkDiscussionResolver{}), request)
+		assert(t, data.Message, "Invalid payload")
+		assert(t, status, http.StatusBadRequest)
+	})
+	t.Run("Reports errors from Gitlab on each discussion", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPut, "/mr/discussions/resolve/bulk", BulkResolveRequest{DiscussionIDs: []string{"a"}, Resolved: gitlab.Ptr(true)})
+		data := getBulkResolveData(t, bulkResolveService(fakeBulkDiscussionResolver{testBase: testBase{errFromGitlab: true}}), request)
+		assert(t, data.Failed, 1)
+		assert(t, data.Results[0].Error, "could not resolve: some error from Gitlab")
+	})
+}
@@ -1,17 +1,23 @@
 package app
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/harrisoncramer/gitlab.nvim/cmd/app/git"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/xanzy/go-gitlab"
 )
 
+const (
+	authTypePrivate = "private"
+	authTypeOAuth   = "oauth"
+	authTypeJob     = "job"
+)
+
 type ProjectInfo struct {
 	ProjectId string
 	MergeId   int
@@ -29,7 +35,12 @@ type Client struct {
 	*gitlab.LabelsService
 	*gitlab.AwardEmojiService
 	*gitlab.UsersService
-	*gitlab.DraftNotesService
+	*DraftNotesService
+	*gitlab.RepositoriesService
+	*gitlab.BranchesService
+	*CommitsService
+	*SuggestionsService
+	*DiffsService
 }
 
 /* NewClient parses and validates the project settings and initializes the Gitlab client. */
@@ -60,18 +71,52 @@ func NewClient() (*Client, error) {
 		))
 	}
 
+	tlsConfig, err := buildTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings: %w", err)
+	}
+
+	proxy, err := buildProxy()
+	if err != nil {
+		return nil, err
+	}
+
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: pluginOptions.ConnectionSettings.Insecure,
-		},
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
 	}
 
-	retryClient := retryablehttp.NewClient()
-	retryClient.HTTPClient.Transport = tr
-	retryClient.RetryMax = 0
-	gitlabOptions = append(gitlabOptions, gitlab.WithHTTPClient(retryClient.HTTPClient))
+	retryMin, retryMax := retryWaits()
+	gitlabOptions = append(gitlabOptions,
+		gitlab.WithCustomRetryMax(pluginOptions.ConnectionSettings.Retries),
+		gitlab.WithCustomRetryWaitMinMax(retryMin, retryMax),
+		gitlab.WithCustomRetry(checkRetry),
+		gitlab.WithCustomBackoff(retryBackoff),
+	)
+
+	token, err := resolveToken()
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := gitlab.NewClient(pluginOptions.AuthToken, gitlabOptions...)
+	httpClient := &http.Client{Transport: tr}
+
+	var client *gitlab.Client
+	switch pluginOptions.AuthType {
+	case "", authTypePrivate:
+		gitlabOptions = append(gitlabOptions, gitlab.WithHTTPClient(httpClient))
+		client, err = gitlab.NewClient(token, gitlabOptions...)
+	case authTypeOAuth:
+		/* The transport keeps the Authorization header up to date as the token is refreshed */
+		httpClient.Transport = newOAuthTransport(tr, token, os.Getenv(oauthRefreshTokenEnvVariable), pluginOptions.OAuthTokenFile)
+		gitlabOptions = append(gitlabOptions, gitlab.WithHTTPClient(httpClient))
+		client, err = gitlab.NewOAuthClient(token, gitlabOptions...)
+	case authTypeJob:
+		gitlabOptions = append(gitlabOptions, gitlab.WithHTTPClient(httpClient))
+		client, err = gitlab.NewJobClient(token, gitlabOptions...)
+	default:
+		return nil, fmt.Errorf("unknown auth_type '%s', expected one of: %s, %s, %s", pluginOptions.AuthType, authTypePrivate, authTypeOAuth, authTypeJob)
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %v", err)
@@ -88,7 +133,12 @@ func NewClient() (*Client, error) {
 		LabelsService:                client.Labels,
 		AwardEmojiService:            client.AwardEmoji,
 		UsersService:                 client.Users,
-		DraftNotesService:            client.DraftNotes,
+		DraftNotesService:            &DraftNotesService{DraftNotesService: client.DraftNotes, client: client},
+		RepositoriesService:          client.Repositories,
+		BranchesService:              client.Branches,
+		CommitsService:               &CommitsService{CommitsService: client.Commits, client: client},
+		SuggestionsService:           &SuggestionsService{client: client},
+		DiffsService:                 &DiffsService{client: client},
 	}, nil
 }
 
@@ -46,7 +46,7 @@ type DeleteCommentRequest struct {
 func (a commentService) deleteComment(w http.ResponseWriter, r *http.Request) {
 	payload := r.Context().Value(payload("payload")).(*DeleteCommentRequest)
 
-	res, err := a.client.DeleteMergeRequestDiscussionNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, payload.DiscussionId, payload.NoteId)
+	res, err := a.client.DeleteMergeRequestDiscussionNote(a.projectInfo.ProjectId, a.mergeId(r), payload.DiscussionId, payload.NoteId)
 
 	if err != nil {
 		handleError(w, err, "Could not delete comment", http.StatusInternalServerError)
@@ -68,7 +68,8 @@ func (a commentService) deleteComment(w http.ResponseWriter, r *http.Request) {
 }
 
 type PostCommentRequest struct {
-	Comment string `json:"comment" validate:"required"`
+	Comment  string `json:"comment" validate:"required"`
+	Internal bool   `json:"internal"`
 	PositionData
 }
 
@@ -97,7 +98,7 @@ func (a commentService) postComment(w http.ResponseWriter, r *http.Request) {
 		opt.Position = buildCommentPosition(commentWithPositionData)
 	}
 
-	discussion, res, err := a.client.CreateMergeRequestDiscussion(a.projectInfo.ProjectId, a.projectInfo.MergeId, &opt)
+	discussion, res, err := a.client.CreateMergeRequestDiscussion(a.projectInfo.ProjectId, a.mergeId(r), &opt, internalNoteOptions(payload.Internal)...)
 
 	if err != nil {
 		handleError(w, err, "Could not create discussion", http.StatusInternalServerError)
@@ -138,7 +139,7 @@ func (a commentService) editComment(w http.ResponseWriter, r *http.Request) {
 		Body: gitlab.Ptr(payload.Comment),
 	}
 
-	note, res, err := a.client.UpdateMergeRequestDiscussionNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, payload.DiscussionId, payload.NoteId, &options)
+	note, res, err := a.client.UpdateMergeRequestDiscussionNote(a.projectInfo.ProjectId, a.mergeId(r), payload.DiscussionId, payload.NoteId, &options, withRetries())
 
 	if err != nil {
 		handleError(w, err, "Could not update comment", http.StatusInternalServerError)
@@ -2,11 +2,21 @@ package app
 
 import (
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 
+	"github.com/go-playground/validator/v10"
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/xanzy/go-gitlab"
 )
 
+/* The position types Gitlab supports: a line of a file, a whole file, or a point on a changed image */
+const (
+	positionTypeText  = "text"
+	positionTypeFile  = "file"
+	positionTypeImage = "image"
+)
+
 /* LinePosition represents a position in a line range. Unlike the Gitlab struct, this does not contain LineCode with a sha1 of the filename */
 type LinePosition struct {
 	Type    string `json:"type"`
@@ -20,7 +30,11 @@ type LineRange struct {
 	EndRange   *LinePosition `json:"end"`
 }
 
-/* PositionData represents the position of a comment or note (relative to a file diff) */
+/*
+PositionData represents the position of a comment or note (relative to a file diff). Text positions point at
+a line or line range, file positions at the whole file, and image positions at a point on a changed image
+given by the image's width and height and the x and y coordinates on it.
+*/
 type PositionData struct {
 	FileName       string     `json:"file_name"`
 	OldFileName    string     `json:"old_file_name"`
@@ -29,8 +43,67 @@ type PositionData struct {
 	HeadCommitSHA  string     `json:"head_commit_sha"`
 	BaseCommitSHA  string     `json:"base_commit_sha"`
 	StartCommitSHA string     `json:"start_commit_sha"`
-	Type           string     `json:"type"`
+	Type           string     `json:"type" validate:"omitempty,oneof=text file image"`
 	LineRange      *LineRange `json:"line_range,omitempty"`
+	Width          *int       `json:"width,omitempty"`
+	Height         *int       `json:"height,omitempty"`
+	X              *float64   `json:"x,omitempty"`
+	Y              *float64   `json:"y,omitempty"`
+}
+
+/* validatePositionData checks that only the fields that belong to the position's type are set */
+func validatePositionData(sl validator.StructLevel) {
+	position := sl.Current().Interface().(PositionData)
+
+	/* Without a file, the comment is a note on the merge request and has no position */
+	if position.FileName == "" {
+		return
+	}
+
+	positionType := position.Type
+	if positionType == "" {
+		positionType = positionTypeText
+	}
+
+	lineFields := []positionField{
+		{"NewLine", position.NewLine != nil},
+		{"OldLine", position.OldLine != nil},
+		{"LineRange", position.LineRange != nil},
+	}
+	imageFields := []positionField{
+		{"Width", position.Width != nil},
+		{"Height", position.Height != nil},
+		{"X", position.X != nil},
+		{"Y", position.Y != nil},
+	}
+
+	excluded := func(fields []positionField) {
+		for _, field := range fields {
+			if field.set {
+				sl.ReportError(field.set, field.name, field.name, "excluded_for_position", positionType)
+			}
+		}
+	}
+
+	switch positionType {
+	case positionTypeText:
+		excluded(imageFields)
+	case positionTypeFile:
+		excluded(lineFields)
+		excluded(imageFields)
+	case positionTypeImage:
+		for _, field := range imageFields {
+			if !field.set {
+				sl.ReportError(field.set, field.name, field.name, "required_for_position", positionType)
+			}
+		}
+		excluded(lineFields)
+	}
+}
+
+type positionField struct {
+	name string
+	set  bool
 }
 
 /* RequestWithPosition is an interface that abstracts the handling of position data for a comment or a draft comment */
@@ -57,22 +130,15 @@ func buildCommentPosition(commentWithPositionData RequestWithPosition) *gitlab.P
 		OldPath:      &oldFileName,
 		NewLine:      positionData.NewLine,
 		OldLine:      positionData.OldLine,
+		Width:        positionData.Width,
+		Height:       positionData.Height,
+		X:            positionData.X,
+		Y:            positionData.Y,
 	}
 
 	if positionData.LineRange != nil {
-		shaFormat := "%x_%d_%d"
-		startFilenameSha := fmt.Sprintf(
-			shaFormat,
-			sha1.Sum([]byte(positionData.FileName)),
-			positionData.LineRange.StartRange.OldLine,
-			positionData.LineRange.StartRange.NewLine,
-		)
-		endFilenameSha := fmt.Sprintf(
-			shaFormat,
-			sha1.Sum([]byte(positionData.FileName)),
-			positionData.LineRange.EndRange.OldLine,
-			positionData.LineRange.EndRange.NewLine,
-		)
+		startFilenameSha := lineCode(positionData.FileName, positionData.LineRange.StartRange)
+		endFilenameSha := lineCode(positionData.FileName, positionData.LineRange.EndRange)
 		opt.LineRange = &gitlab.LineRangeOptions{
 			Start: &gitlab.LinePositionOptions{
 				Type:     &positionData.LineRange.StartRange.Type,
@@ -87,3 +153,53 @@ func buildCommentPosition(commentWithPositionData RequestWithPosition) *gitlab.P
 
 	return opt
 }
+
+/* lineCode identifies a line of a diff the way Gitlab does, by the sha1 of the file name and the line numbers */
+func lineCode(fileName string, line *LinePosition) string {
+	return fmt.Sprintf("%x_%d_%d", sha1.Sum([]byte(fileName)), line.OldLine, line.NewLine)
+}
+
+/*
+internalNoteOptions marks the note being created as internal, so that only project members with at least the
+Reporter role can see it. The go-gitlab option structs have no field for this, so the flag is added to the
+request body instead.
+*/
+func internalNoteOptions(internal bool) []gitlab.RequestOptionFunc {
+	if !internal {
+		return nil
+	}
+	return []gitlab.RequestOptionFunc{withJSONBodyField("internal", true)}
+}
+
+/* withJSONBodyField sets a field on the JSON body of a request */
+func withJSONBodyField(name string, value any) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		body, err := req.BodyBytes()
+		if err != nil {
+			return err
+		}
+
+		fields := map[string]any{}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &fields); err != nil {
+				return fmt.Errorf("could not add %s to request: %w", name, err)
+			}
+		}
+		fields[name] = value
+
+		body, err = json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		return req.SetBody(body)
+	}
+}
+
+/* normalizeInternalNotes flags notes Gitlab marked confidential, which older versions used for internal notes */
+func normalizeInternalNotes(discussions []*gitlab.Discussion) {
+	for _, discussion := range discussions {
+		for _, note := range discussion.Notes {
+			note.Internal = note.Internal || note.Confidential
+		}
+	}
+}
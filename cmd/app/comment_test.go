@@ -1,12 +1,37 @@
 package app
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/xanzy/go-gitlab"
 )
 
+/* requestsInternalNote applies the request options to a request the way go-gitlab does and checks the body */
+func requestsInternalNote(options []gitlab.RequestOptionFunc) bool {
+	req, err := retryablehttp.NewRequest(http.MethodPost, "https://gitlab.com/api/v4/notes", []byte(`{"body":"Some comment"}`))
+	if err != nil {
+		return false
+	}
+	for _, option := range options {
+		if err := option(req); err != nil {
+			return false
+		}
+	}
+	body, err := req.BodyBytes()
+	if err != nil {
+		return false
+	}
+	var fields struct {
+		Body     string `json:"body"`
+		Internal bool   `json:"internal"`
+	}
+	return json.Unmarshal(body, &fields) == nil && fields.Body == "Some comment" && fields.Internal
+}
+
 type fakeCommentClient struct {
 	testBase
 }
@@ -17,7 +42,7 @@ func (f fakeCommentClient) CreateMergeRequestDiscussion(pid interface{}, mergeRe
 		return nil, nil, err
 	}
 
-	return &gitlab.Discussion{Notes: []*gitlab.Note{{}}}, resp, err
+	return &gitlab.Discussion{Notes: []*gitlab.Note{{Internal: requestsInternalNote(options)}}}, resp, err
 }
 func (f fakeCommentClient) UpdateMergeRequestDiscussionNote(pid interface{}, mergeRequest int, discussion string, note int, opt *gitlab.UpdateMergeRequestDiscussionNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error) {
 	resp, err := f.handleGitlabError()
@@ -54,6 +79,24 @@ func TestPostComment(t *testing.T) {
 		assert(t, data.Message, "Comment created successfully")
 	})
 
+	t.Run("Creates an internal note", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment", PostCommentRequest{Comment: "Some comment", Internal: true})
+		svc := middleware(
+			commentService{testProjectData, fakeCommentClient{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostCommentRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		res := httptest.NewRecorder()
+		svc.ServeHTTP(res, request)
+		var data CommentResponse
+		err := json.Unmarshal(res.Body.Bytes(), &data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, data.Comment.Internal, true)
+	})
+
 	t.Run("Creates a new comment", func(t *testing.T) {
 		testCommentCreationData := PostCommentRequest{ // Re-create comment creation data to avoid mutating this variable in other tests
 			Comment: "Some comment",
@@ -146,3 +189,74 @@ func TestEditComment(t *testing.T) {
 		assert(t, data.Message, "Comment updated successfully")
 	})
 }
+
+func TestPostCommentPositionTypes(t *testing.T) {
+	newSvc := func() http.HandlerFunc {
+		return middleware(
+			commentService{testProjectData, fakeCommentClient{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostCommentRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+	}
+
+	t.Run("Creates a comment on a whole file", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment", PostCommentRequest{
+			Comment:      "Some comment",
+			PositionData: PositionData{FileName: "file.txt", Type: "file"},
+		})
+		data := getSuccessData(t, newSvc(), request)
+		assert(t, data.Message, "Comment created successfully")
+	})
+	t.Run("Creates a comment on an image", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment", PostCommentRequest{
+			Comment: "Some comment",
+			PositionData: PositionData{
+				FileName: "logo.png",
+				Type:     "image",
+				Width:    gitlab.Ptr(100),
+				Height:   gitlab.Ptr(50),
+				X:        gitlab.Ptr(10.5),
+				Y:        gitlab.Ptr(20.0),
+			},
+		})
+		data := getSuccessData(t, newSvc(), request)
+		assert(t, data.Message, "Comment created successfully")
+	})
+	t.Run("Rejects an image position without coordinates", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment", PostCommentRequest{
+			Comment:      "Some comment",
+			PositionData: PositionData{FileName: "logo.png", Type: "image", Width: gitlab.Ptr(100), Height: gitlab.Ptr(50)},
+		})
+		data, status := getFailData(t, newSvc(), request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Details, "X is required for image positions; Y is required for image positions")
+	})
+	t.Run("Rejects lines on a file position", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment", PostCommentRequest{
+			Comment:      "Some comment",
+			PositionData: PositionData{FileName: "file.txt", Type: "file", NewLine: gitlab.Ptr(3)},
+		})
+		data, status := getFailData(t, newSvc(), request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Details, "NewLine is not allowed for file positions")
+	})
+	t.Run("Rejects image fields on a text position", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment", PostCommentRequest{
+			Comment:      "Some comment",
+			PositionData: PositionData{FileName: "file.txt", Type: "text", NewLine: gitlab.Ptr(3), X: gitlab.Ptr(1.0)},
+		})
+		data, status := getFailData(t, newSvc(), request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Details, "X is not allowed for text positions")
+	})
+	t.Run("Rejects unknown position types", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment", PostCommentRequest{
+			Comment:      "Some comment",
+			PositionData: PositionData{FileName: "file.txt", Type: "folder"},
+		})
+		data, status := getFailData(t, newSvc(), request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Details, "Type must be one of: text file image")
+	})
+}
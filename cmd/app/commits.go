@@ -0,0 +1,346 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const commitsPerPage = 100
+
+type MergeRequestCommitsLister interface {
+	ListAllMergeRequestCommits(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) ([]*gitlab.Commit, *gitlab.Response, error)
+}
+
+type CommitReviewer interface {
+	MergeRequestCommitsLister
+	GetCommit(pid interface{}, sha string, opt *gitlab.GetCommitOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Commit, *gitlab.Response, error)
+	GetAllCommitDiffs(pid interface{}, sha string, options ...gitlab.RequestOptionFunc) ([]*gitlab.Diff, *gitlab.Response, error)
+	ListCommitDiscussions(pid interface{}, commit string, opt *gitlab.ListCommitDiscussionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Discussion, *gitlab.Response, error)
+	CreateCommitDiscussion(pid interface{}, commit string, opt *gitlab.CreateCommitDiscussionOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Discussion, *gitlab.Response, error)
+}
+
+type commitsService struct {
+	data
+	client CommitReviewer
+}
+
+type ListCommitsResponse struct {
+	SuccessResponse
+	Commits []*gitlab.Commit `json:"commits"`
+}
+
+type CommitDiffResponse struct {
+	SuccessResponse
+	Commit *gitlab.Commit `json:"commit"`
+	Diffs  []*gitlab.Diff `json:"diffs"`
+}
+
+type CommitDiscussionsResponse struct {
+	SuccessResponse
+	Commit      *gitlab.Commit       `json:"commit"`
+	Discussions []*gitlab.Discussion `json:"discussions"`
+}
+
+/*
+PostCommitDiscussionRequest comments on a single commit of the merge request. The position uses the same line model
+as comments on the merge request's diff, but the commit SHAs are filled in from the commit itself, since the
+diff being commented on is the one between the commit and its parent.
+*/
+type PostCommitDiscussionRequest struct {
+	Comment string `json:"comment" validate:"required"`
+	PositionData
+}
+
+type CommitDiscussionResponse struct {
+	SuccessResponse
+	Discussion *gitlab.Discussion `json:"discussion"`
+}
+
+/*
+commitsHandler serves the commits that make up the merge request. /mr/commits lists them, /mr/commits/<sha>/diff
+returns a single commit's changes, and /mr/commits/<sha>/discussions lists and creates discussions on that commit.
+*/
+func (a commitsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/mr/commits" {
+		a.listCommits(w, r)
+		return
+	}
+
+	sha, resource, found := strings.Cut(strings.TrimPrefix(r.URL.Path, "/mr/commits/"), "/")
+	if !found || sha == "" {
+		handleError(w, InvalidRequestError{fmt.Sprintf("unknown path %s", r.URL.Path)}, "Invalid commit path", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case resource == "diff" && r.Method == http.MethodGet:
+		a.getCommitDiff(w, r, sha)
+	case resource == "discussions" && r.Method == http.MethodGet:
+		a.listCommitDiscussions(w, r, sha)
+	case resource == "discussions" && r.Method == http.MethodPost:
+		a.postCommitDiscussion(w, r, sha)
+	case resource == "diff" || resource == "discussions":
+		handleError(w, InvalidRequestError{"Expected: GET; POST"}, "Invalid request type", http.StatusMethodNotAllowed)
+	default:
+		handleError(w, InvalidRequestError{fmt.Sprintf("unknown path %s", r.URL.Path)}, "Invalid commit path", http.StatusNotFound)
+	}
+}
+
+/* listCommits lists the commits of the merge request, newest first as Gitlab returns them */
+func (a commitsService) listCommits(w http.ResponseWriter, r *http.Request) {
+	commits, res, err := a.client.ListAllMergeRequestCommits(a.projectInfo.ProjectId, a.mergeId(r))
+	if err != nil {
+		handleError(w, err, "Could not list commits", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not list commits", res.StatusCode)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := ListCommitsResponse{
+		SuccessResponse: SuccessResponse{Message: "Commits retrieved"},
+		Commits:         commits,
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/* getCommitDiff returns the changes a single commit of the merge request made to each file */
+func (a commitsService) getCommitDiff(w http.ResponseWriter, r *http.Request, sha string) {
+	commit, ok := a.findCommit(w, r, sha)
+	if !ok {
+		return
+	}
+
+	diffs, res, err := a.client.GetAllCommitDiffs(a.projectInfo.ProjectId, commit.ID)
+	if err != nil {
+		handleError(w, err, "Could not get commit diff", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not get commit diff", res.StatusCode)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := CommitDiffResponse{
+		SuccessResponse: SuccessResponse{Message: "Commit diff retrieved"},
+		Commit:          commit,
+		Diffs:           diffs,
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/* listCommitDiscussions lists the discussions left on a single commit of the merge request */
+func (a commitsService) listCommitDiscussions(w http.ResponseWriter, r *http.Request, sha string) {
+	commit, ok := a.findCommit(w, r, sha)
+	if !ok {
+		return
+	}
+
+	var discussions []*gitlab.Discussion
+	opt := &gitlab.ListCommitDiscussionsOptions{Page: 1, PerPage: discussionsPerPage}
+	for {
+		page, res, err := a.client.ListCommitDiscussions(a.projectInfo.ProjectId, commit.ID, opt)
+		if err != nil {
+			handleError(w, err, "Could not list commit discussions", http.StatusInternalServerError)
+			return
+		}
+
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, "Could not list commit discussions", res.StatusCode)
+			return
+		}
+
+		discussions = append(discussions, page...)
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	normalizeInternalNotes(discussions)
+
+	w.WriteHeader(http.StatusOK)
+	response := CommitDiscussionsResponse{
+		SuccessResponse: SuccessResponse{Message: "Commit discussions retrieved"},
+		Commit:          commit,
+		Discussions:     discussions,
+	}
+
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/* postCommitDiscussion starts a discussion on a single commit of the merge request, on a line or on the whole commit */
+func (a commitsService) postCommitDiscussion(w http.ResponseWriter, r *http.Request, sha string) {
+	payload := r.Context().Value(payload("payload")).(*PostCommitDiscussionRequest)
+
+	commit, ok := a.findCommit(w, r, sha)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	opt := &gitlab.CreateCommitDiscussionOptions{
+		Body:      gitlab.Ptr(payload.Comment),
+		CreatedAt: &now,
+	}
+
+	if payload.FileName != "" {
+		/* Gitlab leaves the parents out of the merge request's commit list, the position needs the first one */
+		fullCommit, res, err := a.client.GetCommit(a.projectInfo.ProjectId, commit.ID, &gitlab.GetCommitOptions{})
+		if err != nil {
+			handleError(w, err, "Could not get commit", http.StatusInternalServerError)
+			return
+		}
+
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, "Could not get commit", res.StatusCode)
+			return
+		}
+
+		position, err := buildCommitPosition(fullCommit, payload.PositionData)
+		if err != nil {
+			handleError(w, err, "Could not create commit discussion", http.StatusBadRequest)
+			return
+		}
+		opt.Position = position
+	}
+
+	discussion, res, err := a.client.CreateCommitDiscussion(a.projectInfo.ProjectId, commit.ID, opt)
+	if err != nil {
+		handleError(w, err, "Could not create commit discussion", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not create commit discussion", res.StatusCode)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := CommitDiscussionResponse{
+		SuccessResponse: SuccessResponse{Message: "Commit discussion created"},
+		Discussion:      discussion,
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/*
+findCommit looks the SHA up among the merge request's commits, so that only commits under review can be read or
+commented on. Abbreviated SHAs are accepted as long as they match a single commit.
+*/
+func (a commitsService) findCommit(w http.ResponseWriter, r *http.Request, sha string) (*gitlab.Commit, bool) {
+	commits, res, err := a.client.ListAllMergeRequestCommits(a.projectInfo.ProjectId, a.mergeId(r))
+	if err != nil {
+		handleError(w, err, "Could not list commits", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not list commits", res.StatusCode)
+		return nil, false
+	}
+
+	var found *gitlab.Commit
+	for _, commit := range commits {
+		if !strings.HasPrefix(commit.ID, sha) {
+			continue
+		}
+		if found != nil {
+			handleError(w, fmt.Errorf("%s matches more than one commit", sha), "Ambiguous commit SHA", http.StatusBadRequest)
+			return nil, false
+		}
+		found = commit
+	}
+
+	if found == nil {
+		handleError(w, fmt.Errorf("commit %s is not part of merge request !%d", sha, a.mergeId(r)), "Could not find commit", http.StatusNotFound)
+		return nil, false
+	}
+
+	return found, true
+}
+
+/*
+buildCommitPosition anchors a position on the diff between the commit and its first parent. Only lines can be
+commented on, Gitlab does not support file or image positions on commits.
+*/
+func buildCommitPosition(commit *gitlab.Commit, positionData PositionData) (*gitlab.NotePosition, error) {
+	if positionData.Type != "" && positionData.Type != positionTypeText {
+		return nil, fmt.Errorf("%s positions are not supported on commits", positionData.Type)
+	}
+
+	if positionData.NewLine == nil && positionData.OldLine == nil {
+		return nil, errors.New("a line is required to comment on a file of a commit")
+	}
+
+	if len(commit.ParentIDs) == 0 {
+		return nil, fmt.Errorf("commit %s has no parent to compare it to", commit.ShortID)
+	}
+
+	oldFileName := positionData.OldFileName
+	if oldFileName == "" {
+		oldFileName = positionData.FileName
+	}
+
+	parent := commit.ParentIDs[0]
+	position := &gitlab.NotePosition{
+		BaseSHA:      parent,
+		StartSHA:     parent,
+		HeadSHA:      commit.ID,
+		PositionType: positionTypeText,
+		NewPath:      positionData.FileName,
+		OldPath:      oldFileName,
+	}
+
+	if positionData.NewLine != nil {
+		position.NewLine = *positionData.NewLine
+	}
+	if positionData.OldLine != nil {
+		position.OldLine = *positionData.OldLine
+	}
+
+	if lineRange := positionData.LineRange; lineRange != nil && lineRange.StartRange != nil && lineRange.EndRange != nil {
+		position.LineRange = &gitlab.LineRange{
+			StartRange: &gitlab.LinePosition{
+				LineCode: lineCode(positionData.FileName, lineRange.StartRange),
+				Type:     lineRange.StartRange.Type,
+				OldLine:  lineRange.StartRange.OldLine,
+				NewLine:  lineRange.StartRange.NewLine,
+			},
+			EndRange: &gitlab.LinePosition{
+				LineCode: lineCode(positionData.FileName, lineRange.EndRange),
+				Type:     lineRange.EndRange.Type,
+				OldLine:  lineRange.EndRange.OldLine,
+				NewLine:  lineRange.EndRange.NewLine,
+			},
+		}
+	}
+
+	return position, nil
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeCommitReviewer struct {
+	testBase
+	created *gitlab.CreateCommitDiscussionOptions
+}
+
+func (f fakeCommitReviewer) ListAllMergeRequestCommits(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) ([]*gitlab.Commit, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	/* Like Gitlab, the merge request's commit list has no parents */
+	return []*gitlab.Commit{
+		{ID: "aab222", ShortID: "aab2", Title: "Second"},
+		{ID: "aaa111", ShortID: "aaa1", Title: "First"},
+	}, resp, err
+}
+
+func (f fakeCommitReviewer) GetCommit(pid interface{}, sha string, opt *gitlab.GetCommitOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Commit, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parents := map[string][]string{"aaa111": {"base000"}, "aab222": {"aaa111"}}
+	return &gitlab.Commit{ID: sha, ShortID: sha[:4], ParentIDs: parents[sha]}, resp, err
+}
+
+func (f fakeCommitReviewer) GetAllCommitDiffs(pid interface{}, sha string, options ...gitlab.RequestOptionFunc) ([]*gitlab.Diff, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*gitlab.Diff{{OldPath: "main.go", NewPath: "main.go", Diff: "@@ -1 +1 @@\n-a\n+" + sha + "\n"}}, resp, err
+}
+
+func (f fakeCommitReviewer) ListCommitDiscussions(pid interface{}, commit string, opt *gitlab.ListCommitDiscussionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*gitlab.Discussion{{ID: commit + "-discussion"}}, resp, err
+}
+
+func (f fakeCommitReviewer) CreateCommitDiscussion(pid interface{}, commit string, opt *gitlab.CreateCommitDiscussionOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Discussion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if f.created != nil {
+		*f.created = *opt
+	}
+	return &gitlab.Discussion{ID: commit + "-discussion"}, resp, err
+}
+
+func decodeResponse[T any](t *testing.T, svc http.Handler, request *http.Request) T {
+	res := httptest.NewRecorder()
+	svc.ServeHTTP(res, request)
+
+	var data T
+	err := json.Unmarshal(res.Body.Bytes(), &data)
+	if err != nil {
+		t.Error(err)
+	}
+	return data
+}
+
+func TestCommitsHandler(t *testing.T) {
+	commitsService := func(client CommitReviewer) http.HandlerFunc {
+		return middleware(
+			commitsService{testProjectData, client},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostCommitDiscussionRequest]}),
+			withMethodCheck(http.MethodGet, http.MethodPost),
+		)
+	}
+
+	t.Run("Lists every commit of the merge request", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits", nil)
+		data := decodeResponse[ListCommitsResponse](t, commitsService(fakeCommitReviewer{}), request)
+		assert(t, data.Message, "Commits retrieved")
+		assert(t, len(data.Commits), 2)
+		assert(t, data.Commits[0].Title, "Second")
+	})
+	t.Run("Gets the diff of a commit by its abbreviated SHA", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits/aab/diff", nil)
+		data := decodeResponse[CommitDiffResponse](t, commitsService(fakeCommitReviewer{}), request)
+		assert(t, data.Message, "Commit diff retrieved")
+		assert(t, data.Commit.ID, "aab222")
+		assert(t, data.Diffs[0].Diff, "@@ -1 +1 @@\n-a\n+aab222\n")
+	})
+	t.Run("Rejects SHAs matching several commits", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits/aa/diff", nil)
+		data, status := getFailData(t, commitsService(fakeCommitReviewer{}), request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Details, "aa matches more than one commit")
+	})
+	t.Run("Rejects commits outside of the merge request", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits/fff/discussions", nil)
+		data, status := getFailData(t, commitsService(fakeCommitReviewer{}), request)
+		assert(t, status, http.StatusNotFound)
+		assert(t, data.Message, "Could not find commit")
+	})
+	t.Run("Lists the discussions on a commit", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits/aaa111/discussions", nil)
+		data := decodeResponse[CommitDiscussionsResponse](t, commitsService(fakeCommitReviewer{}), request)
+		assert(t, data.Discussions[0].ID, "aaa111-discussion")
+	})
+	t.Run("Comments on a line of a commit listed without its parents", func(t *testing.T) {
+		var created gitlab.CreateCommitDiscussionOptions
+		payload := PostCommitDiscussionRequest{Comment: "Why?", PositionData: PositionData{FileName: "main.go", NewLine: gitlab.Ptr(1)}}
+		request := makeRequest(t, http.MethodPost, "/mr/commits/aab222/discussions", payload)
+		data := decodeResponse[CommitDiscussionResponse](t, commitsService(fakeCommitReviewer{created: &created}), request)
+		assert(t, data.Message, "Commit discussion created")
+		assert(t, *created.Body, "Why?")
+		assert(t, created.Position.HeadSHA, "aab222")
+		assert(t, created.Position.BaseSHA, "aaa111")
+		assert(t, created.Position.OldPath, "main.go")
+		assert(t, created.Position.NewLine, 1)
+	})
+	t.Run("Rejects image positions on commits", func(t *testing.T) {
+		payload := PostCommitDiscussionRequest{Comment: "Why?", PositionData: PositionData{FileName: "logo.png", Type: "image", Width: gitlab.Ptr(1), Height: gitlab.Ptr(1), X: gitlab.Ptr(0.0), Y: gitlab.Ptr(0.0)}}
+		request := makeRequest(t, http.MethodPost, "/mr/commits/aab222/discussions", payload)
+		data, status := getFailData(t, commitsService(fakeCommitReviewer{}), request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Details, "image positions are not supported on commits")
+	})
+	t.Run("Rejects unknown commit resources", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits/aab222/files", nil)
+		_, status := getFailData(t, commitsService(fakeCommitReviewer{}), request)
+		assert(t, status, http.StatusNotFound)
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits", nil)
+		data, status := getFailData(t, commitsService(fakeCommitReviewer{testBase: testBase{errFromGitlab: true}}), request)
+		assert(t, status, http.StatusInternalServerError)
+		checkErrorFromGitlab(t, data, "Could not list commits")
+	})
+	t.Run("Handles non-200s from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/commits", nil)
+		data, _ := getFailData(t, commitsService(fakeCommitReviewer{testBase: testBase{status: http.StatusSeeOther}}), request)
+		checkNon200(t, data, "Could not list commits", "/mr/commits")
+	})
+}
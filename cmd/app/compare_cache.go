@@ -0,0 +1,59 @@
+package app
+
+import (
+	"sync"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+/* Compares hold whole diffs, so only the most recently stored ones are kept */
+const compareCacheSize = 64
+
+/*
+compareCache stores comparisons between two commits. Commits never change, so an entry never goes stale and
+discussions can be listed again without comparing the same versions over and over. A nil cache never hits.
+*/
+type compareCache struct {
+	mu      sync.Mutex
+	entries map[[2]string]*gitlab.Compare
+	order   [][2]string
+}
+
+func newCompareCache() *compareCache {
+	return &compareCache{entries: make(map[[2]string]*gitlab.Compare)}
+}
+
+func (c *compareCache) get(from string, to string) (*gitlab.Compare, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	compare, ok := c.entries[[2]string{from, to}]
+	return compare, ok
+}
+
+/* set stores a comparison, dropping the oldest one once the cache is full */
+func (c *compareCache) set(from string, to string, compare *gitlab.Compare) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := [2]string{from, to}
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	if len(c.order) >= compareCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+
+	c.entries[key] = compare
+	c.order = append(c.order, key)
+}
@@ -1,20 +1,32 @@
 package app
 
 type PluginOptions struct {
-	GitlabUrl string `json:"gitlab_url"`
-	Port      int    `json:"port"`
-	AuthToken string `json:"auth_token"`
-	LogPath   string `json:"log_path"`
-	Debug     struct {
+	GitlabUrl      string `json:"gitlab_url"`
+	Port           int    `json:"port"`
+	SocketPath     string `json:"socket_path"`
+	AuthTokenFile  string `json:"auth_token_file"`
+	AuthType       string `json:"auth_type"`
+	OAuthClientId  string `json:"oauth_client_id"`
+	OAuthTokenFile string `json:"oauth_token_file"`
+	LogPath        string `json:"log_path"`
+	Debug          struct {
 		Request        bool `json:"request"`
 		Response       bool `json:"response"`
 		GitlabRequest  bool `json:"gitlab_request"`
 		GitlabResponse bool `json:"gitlab_response"`
+		Auth           bool `json:"auth"`
 	} `json:"debug"`
 	ChosenMrIID        int `json:"chosen_mr_iid"`
 	ConnectionSettings struct {
-		Insecure bool   `json:"insecure"`
-		Remote   string `json:"remote"`
+		Insecure       bool   `json:"insecure"`
+		Remote         string `json:"remote"`
+		CaFile         string `json:"ca_file"`
+		ClientCertFile string `json:"client_cert_file"`
+		ClientKeyFile  string `json:"client_key_file"`
+		Proxy          string `json:"proxy"`
+		Retries        int    `json:"retries"`
+		RetryWaitMinMs int    `json:"retry_wait_min_ms"`
+		RetryWaitMaxMs int    `json:"retry_wait_max_ms"`
 	} `json:"connection_settings"`
 }
 
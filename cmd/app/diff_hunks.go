@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/* diffLine is a single line of a hunk. Kind is ' ' for context, '-' for removed and '+' for added lines. */
+type diffLine struct {
+	kind    byte
+	oldLine int
+	newLine int
+	text    string
+}
+
+type diffHunk struct {
+	oldStart int
+	oldLines int
+	newStart int
+	newLines int
+	lines    []diffLine
+}
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+/* parseDiffHunks reads the hunks of a single file's unified diff, as Gitlab returns it in the diff field */
+func parseDiffHunks(diff string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	var current *diffHunk
+	oldLine, newLine := 0, 0
+
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := hunkHeaderRegex.FindStringSubmatch(line); match != nil {
+			hunks = append(hunks, diffHunk{
+				oldStart: atoiOr(match[1], 0),
+				oldLines: atoiOr(match[2], 1),
+				newStart: atoiOr(match[3], 0),
+				newLines: atoiOr(match[4], 1),
+			})
+			current = &hunks[len(hunks)-1]
+			oldLine, newLine = current.oldStart, current.newStart
+			continue
+		}
+
+		/* Skip the file headers before the first hunk and the "\ No newline at end of file" markers */
+		if current == nil || strings.HasPrefix(line, `\`) {
+			continue
+		}
+
+		/* Some tools strip the leading space from blank context lines */
+		kind := byte(' ')
+		text := line
+		if len(line) > 0 {
+			kind, text = line[0], line[1:]
+		}
+
+		switch kind {
+		case '-':
+			current.lines = append(current.lines, diffLine{kind: kind, oldLine: oldLine, text: text})
+			oldLine++
+		case '+':
+			current.lines = append(current.lines, diffLine{kind: kind, newLine: newLine, text: text})
+			newLine++
+		case ' ':
+			current.lines = append(current.lines, diffLine{kind: kind, oldLine: oldLine, newLine: newLine, text: text})
+			oldLine++
+			newLine++
+		default:
+			return nil, fmt.Errorf("unexpected line in diff: %q", line)
+		}
+	}
+
+	return hunks, scanner.Err()
+}
+
+/*
+mapLineThroughDiff finds where a line of the old side of the diff ended up on the new side. Lines before and
+between hunks move by the lines added and removed above them, lines inside a hunk keep their context line's
+new number, and removed lines have no new number.
+*/
+func mapLineThroughDiff(hunks []diffHunk, line int) (int, bool) {
+	delta := 0
+	for _, hunk := range hunks {
+		/* A hunk that only adds lines starts after its old start line rather than on it */
+		before := line < hunk.oldStart
+		if hunk.oldLines == 0 {
+			before = line <= hunk.oldStart
+		}
+		if before {
+			return line + delta, true
+		}
+
+		if line < hunk.oldStart+hunk.oldLines {
+			for _, l := range hunk.lines {
+				if l.oldLine != line {
+					continue
+				}
+				if l.kind == '-' {
+					return 0, false
+				}
+				return l.newLine, true
+			}
+		}
+
+		delta += hunk.newLines - hunk.oldLines
+	}
+
+	return line + delta, true
+}
+
+func atoiOr(s string, fallback int) int {
+	if s == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
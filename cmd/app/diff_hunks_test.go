@@ -0,0 +1,36 @@
+package app
+
+import "testing"
+
+func TestMapLineThroughDiff(t *testing.T) {
+	diff := "@@ -1,4 +1,5 @@\n a\n-b\n+B\n+B2\n c\n d\n@@ -10,0 +12,2 @@\n+x\n+y\n@@ -20,2 +23,0 @@\n-gone\n-gone too\n"
+	hunks, err := parseDiffHunks(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, len(hunks), 3)
+
+	cases := []struct {
+		line   int
+		want   int
+		exists bool
+	}{
+		{1, 1, true},
+		{2, 0, false},
+		{3, 4, true},
+		{4, 5, true},
+		{7, 8, true},
+		{10, 11, true},
+		{11, 14, true},
+		{20, 0, false},
+		{21, 0, false},
+		{22, 23, true},
+	}
+
+	for _, c := range cases {
+		got, exists := mapLineThroughDiff(hunks, c.line)
+		if got != c.want || exists != c.exists {
+			t.Errorf("Line %d: got (%d, %v) but wanted (%d, %v)", c.line, got, exists, c.want, c.exists)
+		}
+	}
+}
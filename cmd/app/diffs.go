@@ -0,0 +1,182 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const diffsPerPage = 50
+
+/*
+DiffsRequest picks which diffs to return. Without a version the diffs of the latest version are returned. With
+CompareToVersionID the diffs go from the head of that version to the head of the chosen one, as when comparing
+two versions in Gitlab. Version IDs are the ones returned by /mr/revisions.
+*/
+type DiffsRequest struct {
+	VersionID          int `json:"version_id" validate:"omitempty,min=1"`
+	CompareToVersionID int `json:"compare_to_version_id" validate:"omitempty,min=1"`
+	Page               int `json:"page" validate:"omitempty,min=1"`
+	PerPage            int `json:"per_page" validate:"omitempty,min=1,max=100"`
+}
+
+type DiffsResponse struct {
+	SuccessResponse
+	Version          *gitlab.MergeRequestDiffVersion `json:"version"`
+	CompareToVersion *gitlab.MergeRequestDiffVersion `json:"compare_to_version,omitempty"`
+	Diffs            []*DiffFile                     `json:"diffs"`
+	Page             int                             `json:"page"`
+	PerPage          int                             `json:"per_page"`
+	NextPage         int                             `json:"next_page"`
+}
+
+type MergeRequestDiffsGetter interface {
+	RevisionsGetter
+	ListMergeRequestDiffFiles(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiffsOptions, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error)
+	GetMergeRequestDiffVersionFiles(pid interface{}, mergeRequest int, version int, options ...gitlab.RequestOptionFunc) (*DiffVersionFiles, *gitlab.Response, error)
+	CompareDiffFiles(pid interface{}, from string, to string, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error)
+}
+
+type diffsService struct {
+	data
+	client MergeRequestDiffsGetter
+}
+
+/*
+diffsHandler serves the merge request's file diffs as Gitlab computed them, so that reviewing does not depend on
+the local clone having the target branch or the full history. The latest version is paged through by Gitlab,
+older versions and comparisons come back whole from Gitlab and are paged here.
+*/
+func (a diffsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := r.Context().Value(payload("payload")).(*DiffsRequest)
+	mergeId := a.mergeId(r)
+
+	page, perPage := payload.Page, payload.PerPage
+	if page == 0 {
+		page = 1
+	}
+	if perPage == 0 {
+		perPage = diffsPerPage
+	}
+
+	versions, res, err := a.client.GetMergeRequestDiffVersions(a.projectInfo.ProjectId, mergeId, &gitlab.GetMergeRequestDiffVersionsOptions{})
+	if err != nil {
+		handleError(w, err, "Could not get diff version info", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not get diff version info", res.StatusCode)
+		return
+	}
+
+	if len(versions) == 0 {
+		handleError(w, fmt.Errorf("merge request !%d has no diff versions", mergeId), "Could not get diffs", http.StatusNotFound)
+		return
+	}
+
+	/* Gitlab lists versions newest first */
+	version := versions[0]
+	if payload.VersionID != 0 {
+		version = findDiffVersion(versions, payload.VersionID)
+		if version == nil {
+			handleError(w, fmt.Errorf("version %d is not a version of merge request !%d", payload.VersionID, mergeId), "Could not find diff version", http.StatusNotFound)
+			return
+		}
+	}
+
+	response := DiffsResponse{
+		SuccessResponse: SuccessResponse{Message: "Diffs retrieved"},
+		Version:         version,
+		Page:            page,
+		PerPage:         perPage,
+	}
+
+	switch {
+	case payload.CompareToVersionID != 0:
+		compareTo := findDiffVersion(versions, payload.CompareToVersionID)
+		if compareTo == nil {
+			handleError(w, fmt.Errorf("version %d is not a version of merge request !%d", payload.CompareToVersionID, mergeId), "Could not find diff version", http.StatusNotFound)
+			return
+		}
+
+		diffs, res, err := a.client.CompareDiffFiles(a.projectInfo.ProjectId, compareTo.HeadCommitSHA, version.HeadCommitSHA)
+		if err != nil {
+			handleError(w, err, "Could not compare diff versions", http.StatusInternalServerError)
+			return
+		}
+
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, "Could not compare diff versions", res.StatusCode)
+			return
+		}
+
+		response.CompareToVersion = compareTo
+		response.Diffs, response.NextPage = paginateDiffs(diffs, page, perPage)
+
+	case version.ID != versions[0].ID:
+		versionFiles, res, err := a.client.GetMergeRequestDiffVersionFiles(a.projectInfo.ProjectId, mergeId, version.ID)
+		if err != nil {
+			handleError(w, err, "Could not get diffs", http.StatusInternalServerError)
+			return
+		}
+
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, "Could not get diffs", res.StatusCode)
+			return
+		}
+
+		response.Diffs, response.NextPage = paginateDiffs(versionFiles.Diffs, page, perPage)
+
+	default:
+		opt := &gitlab.ListMergeRequestDiffsOptions{ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage}}
+		diffs, res, err := a.client.ListMergeRequestDiffFiles(a.projectInfo.ProjectId, mergeId, opt)
+		if err != nil {
+			handleError(w, err, "Could not get diffs", http.StatusInternalServerError)
+			return
+		}
+
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, "Could not get diffs", res.StatusCode)
+			return
+		}
+
+		response.Diffs, response.NextPage = diffs, res.NextPage
+	}
+
+	if response.Diffs == nil {
+		response.Diffs = []*DiffFile{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+func findDiffVersion(versions []*gitlab.MergeRequestDiffVersion, id int) *gitlab.MergeRequestDiffVersion {
+	for _, version := range versions {
+		if version.ID == id {
+			return version
+		}
+	}
+	return nil
+}
+
+/* paginateDiffs returns one page of diffs and the number of the page after it, or 0 on the last page */
+func paginateDiffs(diffs []*DiffFile, page int, perPage int) ([]*DiffFile, int) {
+	start := (page - 1) * perPage
+	if start >= len(diffs) {
+		return nil, 0
+	}
+
+	end := start + perPage
+	if end >= len(diffs) {
+		return diffs[start:], 0
+	}
+	return diffs[start:end], page + 1
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeMergeRequestDiffsGetter struct {
+	testBase
+}
+
+func (f fakeMergeRequestDiffsGetter) GetMergeRequestDiffVersions(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestDiffVersionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.MergeRequestDiffVersion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*gitlab.MergeRequestDiffVersion{
+		{ID: 2, HeadCommitSHA: "head222", BaseCommitSHA: "base000"},
+		{ID: 1, HeadCommitSHA: "head111", BaseCommitSHA: "base000"},
+	}, resp, err
+}
+
+func (f fakeMergeRequestDiffsGetter) ListMergeRequestDiffFiles(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiffsOptions, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp.NextPage = opt.Page + 1
+	return []*DiffFile{
+		{OldPath: "main.go", NewPath: "main.go", Diff: "@@ -1 +1 @@\n-a\n+b\n"},
+		{OldPath: "vendor.js", NewPath: "vendor.js", TooLarge: true},
+	}, resp, err
+}
+
+func (f fakeMergeRequestDiffsGetter) GetMergeRequestDiffVersionFiles(pid interface{}, mergeRequest int, version int, options ...gitlab.RequestOptionFunc) (*DiffVersionFiles, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &DiffVersionFiles{Diffs: []*DiffFile{
+		{NewPath: "a.go"}, {NewPath: "b.go"}, {NewPath: "c.go"},
+	}}, resp, err
+}
+
+func (f fakeMergeRequestDiffsGetter) CompareDiffFiles(pid interface{}, from string, to string, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*DiffFile{{NewPath: from + ".." + to}}, resp, err
+}
+
+func TestDiffsHandler(t *testing.T) {
+	diffsService := func(client MergeRequestDiffsGetter) http.HandlerFunc {
+		return middleware(
+			diffsService{testProjectData, client},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiffsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+	}
+
+	t.Run("Pages through the diffs of the latest version with Gitlab", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/diffs", DiffsRequest{Page: 3})
+		data := decodeResponse[DiffsResponse](t, diffsService(fakeMergeRequestDiffsGetter{}), request)
+		assert(t, data.Message, "Diffs retrieved")
+		assert(t, data.Version.ID, 2)
+		assert(t, data.PerPage, diffsPerPage)
+		assert(t, data.NextPage, 4)
+		assert(t, data.Diffs[1].TooLarge, true)
+	})
+	t.Run("Pages through the diffs of an older version", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/diffs", DiffsRequest{VersionID: 1, Page: 2, PerPage: 2})
+		data := decodeResponse[DiffsResponse](t, diffsService(fakeMergeRequestDiffsGetter{}), request)
+		assert(t, data.Version.HeadCommitSHA, "head111")
+		assert(t, len(data.Diffs), 1)
+		assert(t, data.Diffs[0].NewPath, "c.go")
+		assert(t, data.NextPage, 0)
+	})
+	t.Run("Compares two versions by their heads", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/diffs", DiffsRequest{CompareToVersionID: 1})
+		data := decodeResponse[DiffsResponse](t, diffsService(fakeMergeRequestDiffsGetter{}), request)
+		assert(t, data.CompareToVersion.ID, 1)
+		assert(t, data.Diffs[0].NewPath, "head111..head222")
+	})
+	t.Run("Rejects versions of other merge requests", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/diffs", DiffsRequest{VersionID: 7})
+		data, status := getFailData(t, diffsService(fakeMergeRequestDiffsGetter{}), request)
+		assert(t, status, http.StatusNotFound)
+		assert(t, data.Message, "Could not find diff version")
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/diffs", DiffsRequest{})
+		data, _ := getFailData(t, diffsService(fakeMergeRequestDiffsGetter{testBase{errFromGitlab: true}}), request)
+		checkErrorFromGitlab(t, data, "Could not get diff version info")
+	})
+	t.Run("Handles non-200s from Gitlab", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/diffs", DiffsRequest{})
+		data, _ := getFailData(t, diffsService(fakeMergeRequestDiffsGetter{testBase{status: http.StatusSeeOther}}), request)
+		checkNon200(t, data, "Could not get diff version info", "/mr/diffs")
+	})
+}
+
+func TestMarkBinaryDiffs(t *testing.T) {
+	diffs := []*DiffFile{
+		{Diff: "Binary files a/logo.png and b/logo.png differ\n"},
+		{Diff: "@@ -1 +1 @@\n-a\n+b\n"},
+	}
+	markBinaryDiffs(diffs)
+	assert(t, diffs[0].Binary, true)
+	assert(t, diffs[1].Binary, false)
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	discussionStateAll        = "all"
+	discussionStateResolved   = "resolved"
+	discussionStateUnresolved = "unresolved"
+)
+
+/*
+DiscussionFilter narrows down the discussions returned to Lua. Every filter that is set must match.
+Authors matches whoever started the discussion, Path is a glob against the file a linked discussion is on
+(** matches across directories), the date range and Text match if any note in the discussion does, and
+MentionsMe keeps discussions where a note mentions the current user.
+*/
+type DiscussionFilter struct {
+	State         string     `json:"state" validate:"omitempty,oneof=all resolved unresolved"`
+	Authors       []string   `json:"authors"`
+	Path          string     `json:"path"`
+	CreatedAfter  *time.Time `json:"created_after"`
+	CreatedBefore *time.Time `json:"created_before"`
+	MentionsMe    bool       `json:"mentions_me"`
+	Text          string     `json:"text"`
+}
+
+/* discussionMatcher is a DiscussionFilter prepared for matching many discussions */
+type discussionMatcher struct {
+	filter  DiscussionFilter
+	path    *regexp.Regexp
+	mention *regexp.Regexp
+	text    string
+}
+
+/* newDiscussionMatcher compiles the filter. The username is only needed when filtering on mentions. */
+func newDiscussionMatcher(filter DiscussionFilter, username string) (*discussionMatcher, error) {
+	m := &discussionMatcher{filter: filter, text: strings.ToLower(filter.Text)}
+
+	if filter.Path != "" {
+		path, err := globToRegexp(filter.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path filter: %w", err)
+		}
+		m.path = path
+	}
+
+	if filter.MentionsMe {
+		m.mention = regexp.MustCompile(`(?i)(^|[^\w.-])@` + regexp.QuoteMeta(username) + `($|[^\w.-])`)
+	}
+
+	return m, nil
+}
+
+func (m *discussionMatcher) matches(discussion *gitlab.Discussion) bool {
+	first := discussion.Notes[0]
+
+	if len(m.filter.Authors) > 0 && !Contains(m.filter.Authors, first.Author.Username) {
+		return false
+	}
+
+	switch m.filter.State {
+	case discussionStateResolved, discussionStateUnresolved:
+		resolvable, resolved := discussionResolution(discussion)
+		if !resolvable || resolved != (m.filter.State == discussionStateResolved) {
+			return false
+		}
+	}
+
+	if m.path != nil {
+		if first.Position == nil || !m.path.MatchString(first.Position.NewPath) && !m.path.MatchString(first.Position.OldPath) {
+			return false
+		}
+	}
+
+	if m.filter.CreatedAfter != nil || m.filter.CreatedBefore != nil || m.mention != nil || m.text != "" {
+		if !m.anyNoteMatches(discussion) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/* anyNoteMatches checks the filters that apply to individual notes, all of them on the same note */
+func (m *discussionMatcher) anyNoteMatches(discussion *gitlab.Discussion) bool {
+	for _, note := range discussion.Notes {
+		if note.System {
+			continue
+		}
+		if m.filter.CreatedAfter != nil && (note.CreatedAt == nil || note.CreatedAt.Before(*m.filter.CreatedAfter)) {
+			continue
+		}
+		if m.filter.CreatedBefore != nil && (note.CreatedAt == nil || note.CreatedAt.After(*m.filter.CreatedBefore)) {
+			continue
+		}
+		if m.mention != nil && !m.mention.MatchString(note.Body) {
+			continue
+		}
+		if m.text != "" && !strings.Contains(strings.ToLower(note.Body), m.text) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+/* discussionResolution reports whether a discussion can be resolved and if all of its resolvable notes are */
+func discussionResolution(discussion *gitlab.Discussion) (resolvable bool, resolved bool) {
+	resolved = true
+	for _, note := range discussion.Notes {
+		if note.Resolvable {
+			resolvable = true
+			resolved = resolved && note.Resolved
+		}
+	}
+	return resolvable, resolvable && resolved
+}
+
+/* globToRegexp turns a path glob into a regular expression. * and ? stay within a directory, ** does not. */
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var s strings.Builder
+	s.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			i++
+			/* A "**" directory also matches no directories at all */
+			if i+1 < len(glob) && glob[i+1] == '/' {
+				i++
+				s.WriteString("(?:.*/)?")
+			} else {
+				s.WriteString(".*")
+			}
+		case c == '*':
+			s.WriteString("[^/]*")
+		case c == '?':
+			s.WriteString("[^/]")
+		default:
+			s.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	s.WriteString("$")
+	return regexp.Compile(s.String())
+}
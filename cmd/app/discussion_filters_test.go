@@ -0,0 +1,83 @@
+package app
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestDiscussionMatcher(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	discussion := func(author string, path string, created int, body string, resolvable bool, resolved bool) *gitlab.Discussion {
+		note := &gitlab.Note{Body: body, CreatedAt: day(created), Resolvable: resolvable, Resolved: resolved}
+		note.Author.Username = author
+		if path != "" {
+			note.Position = &gitlab.NotePosition{NewPath: path, OldPath: path}
+		}
+		return &gitlab.Discussion{Notes: []*gitlab.Note{note}}
+	}
+	match := func(t *testing.T, filter DiscussionFilter, d *gitlab.Discussion) bool {
+		t.Helper()
+		m, err := newDiscussionMatcher(filter, "hcramer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.matches(d)
+	}
+
+	t.Run("Matches resolved and unresolved discussions", func(t *testing.T) {
+		resolved := discussion("a", "", 1, "", true, true)
+		unresolved := discussion("a", "", 1, "", true, false)
+		note := discussion("a", "", 1, "", false, false)
+		assert(t, match(t, DiscussionFilter{State: "resolved"}, resolved), true)
+		assert(t, match(t, DiscussionFilter{State: "resolved"}, unresolved), false)
+		assert(t, match(t, DiscussionFilter{State: "unresolved"}, unresolved), true)
+		assert(t, match(t, DiscussionFilter{State: "unresolved"}, note), false)
+		assert(t, match(t, DiscussionFilter{State: "all"}, note), true)
+	})
+	t.Run("Matches file paths with globs", func(t *testing.T) {
+		d := discussion("a", "cmd/app/server.go", 1, "", false, false)
+		assert(t, match(t, DiscussionFilter{Path: "cmd/**/*.go"}, d), true)
+		assert(t, match(t, DiscussionFilter{Path: "**/server.go"}, d), true)
+		assert(t, match(t, DiscussionFilter{Path: "cmd/*.go"}, d), false)
+		assert(t, match(t, DiscussionFilter{Path: "*.go"}, discussion("a", "", 1, "", false, false)), false)
+	})
+	t.Run("Matches date ranges", func(t *testing.T) {
+		d := discussion("a", "", 5, "", false, false)
+		assert(t, match(t, DiscussionFilter{CreatedAfter: day(4), CreatedBefore: day(6)}, d), true)
+		assert(t, match(t, DiscussionFilter{CreatedAfter: day(6)}, d), false)
+	})
+	t.Run("Matches mentions and text", func(t *testing.T) {
+		assert(t, match(t, DiscussionFilter{MentionsMe: true}, discussion("a", "", 1, "cc @HCramer", false, false)), true)
+		assert(t, match(t, DiscussionFilter{MentionsMe: true}, discussion("a", "", 1, "cc @hcramer2", false, false)), false)
+		assert(t, match(t, DiscussionFilter{MentionsMe: true}, discussion("a", "", 1, "mail hcramer@example.com", false, false)), false)
+		assert(t, match(t, DiscussionFilter{Text: "NIL pointer"}, discussion("a", "", 1, "Possible nil pointer here", false, false)), true)
+		assert(t, match(t, DiscussionFilter{Text: "race"}, discussion("a", "", 1, "Possible nil pointer here", false, false)), false)
+	})
+}
+
+func TestSortByFileAndLine(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(id string, path string, line int) *gitlab.Discussion {
+		var position *gitlab.NotePosition
+		if path != "" {
+			position = &gitlab.NotePosition{NewPath: path, NewLine: line}
+		}
+		return &gitlab.Discussion{ID: id, Notes: []*gitlab.Note{{CreatedAt: &created, Position: position}}}
+	}
+
+	discussions := []*gitlab.Discussion{at("b10", "b.go", 10), at("a20", "a.go", 20), at("b2", "b.go", 2), at("a3", "a.go", 3)}
+	sort.Sort(SortableDiscussions{Discussions: discussions, SortBy: SortByFileAndLine})
+
+	var ids []string
+	for _, d := range discussions {
+		ids = append(ids, d.ID)
+	}
+	assert(t, len(ids), 4)
+	assert(t, ids[0]+","+ids[1]+","+ids[2]+","+ids[3], "a3,a20,b2,b10")
+}
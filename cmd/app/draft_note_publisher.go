@@ -27,9 +27,9 @@ func (a draftNotePublisherService) ServeHTTP(w http.ResponseWriter, r *http.Requ
 	var res *gitlab.Response
 	var err error
 	if payload.Note != 0 {
-		res, err = a.client.PublishDraftNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, payload.Note)
+		res, err = a.client.PublishDraftNote(a.projectInfo.ProjectId, a.mergeId(r), payload.Note)
 	} else {
-		res, err = a.client.PublishAllDraftNotes(a.projectInfo.ProjectId, a.projectInfo.MergeId)
+		res, err = a.client.PublishAllDraftNotes(a.projectInfo.ProjectId, a.mergeId(r))
 	}
 
 	if err != nil {
@@ -29,7 +29,7 @@ func (draftNote DraftNoteWithPosition) GetPositionData() PositionData {
 }
 
 type DraftNoteManager interface {
-	ListDraftNotes(pid interface{}, mergeRequest int, opt *gitlab.ListDraftNotesOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.DraftNote, *gitlab.Response, error)
+	ListDraftNotesWithInternal(pid interface{}, mergeRequest int, opt *gitlab.ListDraftNotesOptions, options ...gitlab.RequestOptionFunc) ([]*DraftNoteWithInternal, *gitlab.Response, error)
 	CreateDraftNote(pid interface{}, mergeRequest int, opt *gitlab.CreateDraftNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.DraftNote, *gitlab.Response, error)
 	DeleteDraftNote(pid interface{}, mergeRequest int, note int, options ...gitlab.RequestOptionFunc) (*gitlab.Response, error)
 	UpdateDraftNote(pid interface{}, mergeRequest int, note int, opt *gitlab.UpdateDraftNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.DraftNote, *gitlab.Response, error)
@@ -57,14 +57,14 @@ func (a draftNoteService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 type ListDraftNotesResponse struct {
 	SuccessResponse
-	DraftNotes []*gitlab.DraftNote `json:"draft_notes"`
+	DraftNotes []*DraftNoteWithInternal `json:"draft_notes"`
 }
 
-/* listDraftNotes lists all draft notes for the currently authenticated user */
+/* listDraftNotes lists all draft notes for the currently authenticated user, flagging the internal ones */
 func (a draftNoteService) listDraftNotes(w http.ResponseWriter, r *http.Request) {
 
 	opt := gitlab.ListDraftNotesOptions{}
-	draftNotes, res, err := a.client.ListDraftNotes(a.projectInfo.ProjectId, a.projectInfo.MergeId, &opt)
+	draftNotes, res, err := a.client.ListDraftNotesWithInternal(a.projectInfo.ProjectId, a.mergeId(r), &opt)
 
 	if err != nil {
 		handleError(w, err, "Could not get draft notes", http.StatusInternalServerError)
@@ -91,6 +91,7 @@ func (a draftNoteService) listDraftNotes(w http.ResponseWriter, r *http.Request)
 type PostDraftNoteRequest struct {
 	Comment      string `json:"comment" validate:"required"`
 	DiscussionId string `json:"discussion_id,omitempty"`
+	Internal     bool   `json:"internal"`
 	PositionData        // TODO: How to add validations to data from external package???
 }
 
@@ -112,7 +113,7 @@ func (a draftNoteService) postDraftNote(w http.ResponseWriter, r *http.Request)
 		opt.Position = buildCommentPosition(draftNoteWithPosition)
 	}
 
-	draftNote, res, err := a.client.CreateDraftNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, &opt)
+	draftNote, res, err := a.client.CreateDraftNote(a.projectInfo.ProjectId, a.mergeId(r), &opt, internalNoteOptions(payload.Internal)...)
 
 	if err != nil {
 		handleError(w, err, "Could not create draft note", http.StatusInternalServerError)
@@ -145,7 +146,7 @@ func (a draftNoteService) deleteDraftNote(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	res, err := a.client.DeleteDraftNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, id)
+	res, err := a.client.DeleteDraftNote(a.projectInfo.ProjectId, a.mergeId(r), id)
 
 	if err != nil {
 		handleError(w, err, "Could not delete draft note", http.StatusInternalServerError)
@@ -192,7 +193,7 @@ func (a draftNoteService) updateDraftNote(w http.ResponseWriter, r *http.Request
 		Position: &payload.Position,
 	}
 
-	draftNote, res, err := a.client.UpdateDraftNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, id, &opt)
+	draftNote, res, err := a.client.UpdateDraftNote(a.projectInfo.ProjectId, a.mergeId(r), id, &opt, withRetries())
 
 	if err != nil {
 		handleError(w, err, "Could not update draft note", http.StatusInternalServerError)
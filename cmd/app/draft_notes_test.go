@@ -1,6 +1,7 @@
 package app
 
 import (
+	"encoding/json"
 	"net/http"
 	"testing"
 
@@ -11,12 +12,15 @@ type fakeDraftNoteManager struct {
 	testBase
 }
 
-func (f fakeDraftNoteManager) ListDraftNotes(pid interface{}, mergeRequest int, opt *gitlab.ListDraftNotesOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.DraftNote, *gitlab.Response, error) {
+func (f fakeDraftNoteManager) ListDraftNotesWithInternal(pid interface{}, mergeRequest int, opt *gitlab.ListDraftNotesOptions, options ...gitlab.RequestOptionFunc) ([]*DraftNoteWithInternal, *gitlab.Response, error) {
 	resp, err := f.handleGitlabError()
 	if err != nil {
 		return nil, nil, err
 	}
-	return []*gitlab.DraftNote{}, resp, err
+	return []*DraftNoteWithInternal{
+		{DraftNote: gitlab.DraftNote{ID: 1, Note: "Public"}},
+		{DraftNote: gitlab.DraftNote{ID: 2, Note: "Reporters only"}, Internal: true},
+	}, resp, err
 }
 
 func (f fakeDraftNoteManager) CreateDraftNote(pid interface{}, mergeRequest int, opt *gitlab.CreateDraftNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.DraftNote, *gitlab.Response, error) {
@@ -55,6 +59,33 @@ func TestListDraftNotes(t *testing.T) {
 		data := getSuccessData(t, svc, request)
 		assert(t, data.Message, "Draft notes fetched successfully")
 	})
+	t.Run("Flags internal draft notes", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/draft_notes/", nil)
+		svc := middleware(
+			draftNoteService{testProjectData, fakeDraftNoteManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{
+				http.MethodPost:  newPayload[PostDraftNoteRequest],
+				http.MethodPatch: newPayload[UpdateDraftNoteRequest],
+			}),
+			withMethodCheck(http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete),
+		)
+
+		data := decodeResponse[ListDraftNotesResponse](t, svc, request)
+		assert(t, data.DraftNotes[0].Internal, false)
+		assert(t, data.DraftNotes[1].Internal, true)
+		assert(t, data.DraftNotes[1].Note, "Reporters only")
+	})
+	t.Run("Reads the internal flag alongside Gitlab's draft note fields", func(t *testing.T) {
+		var draftNote DraftNoteWithInternal
+		err := json.Unmarshal([]byte(`{"id": 3, "note": "Hidden", "discussion_id": "abc", "internal": true}`), &draftNote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, draftNote.ID, 3)
+		assert(t, draftNote.DiscussionID, "abc")
+		assert(t, draftNote.Internal, true)
+	})
 	t.Run("Handles error from Gitlab client", func(t *testing.T) {
 		request := makeRequest(t, http.MethodGet, "/mr/draft_notes/", nil)
 		svc := middleware(
@@ -81,7 +81,8 @@ func (a emojiService) deleteEmojiFromNote(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	res, err := a.client.DeleteMergeRequestAwardEmojiOnNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, noteId, awardableId)
+	res, err := a.client.DeleteMergeRequestAwardEmojiOnNote(a.projectInfo.ProjectId, a.mergeId(r), noteId, awardableId)
+	a.emojiCache.invalidate(noteId)
 
 	if err != nil {
 		handleError(w, err, "Could not delete awardable", http.StatusInternalServerError)
@@ -120,9 +121,10 @@ func (a emojiService) postEmojiOnNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	awardEmoji, res, err := a.client.CreateMergeRequestAwardEmojiOnNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, emojiPost.NoteId, &gitlab.CreateAwardEmojiOptions{
+	awardEmoji, res, err := a.client.CreateMergeRequestAwardEmojiOnNote(a.projectInfo.ProjectId, a.mergeId(r), emojiPost.NoteId, &gitlab.CreateAwardEmojiOptions{
 		Name: emojiPost.Emoji,
 	})
+	a.emojiCache.invalidate(emojiPost.NoteId)
 
 	if err != nil {
 		handleError(w, err, "Could not post emoji", http.StatusInternalServerError)
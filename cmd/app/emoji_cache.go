@@ -0,0 +1,84 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+/*
+Adding or removing an award emoji does not change a note's UpdatedAt timestamp, so cached entries also
+expire after a while in order to pick up reactions left by other users
+*/
+const noteEmojiCacheTTL = 2 * time.Minute
+
+type noteEmojiCacheEntry struct {
+	updatedAt time.Time
+	fetchedAt time.Time
+	emojis    []*gitlab.AwardEmoji
+}
+
+/*
+noteEmojiCache stores the emojis for each note keyed by the note's ID and the time it was last updated,
+so that unchanged notes are not refetched every time discussions are listed. A nil cache never hits.
+*/
+type noteEmojiCache struct {
+	mu      sync.Mutex
+	entries map[int]noteEmojiCacheEntry
+}
+
+func newNoteEmojiCache() *noteEmojiCache {
+	return &noteEmojiCache{entries: make(map[int]noteEmojiCacheEntry)}
+}
+
+/* get returns the cached emojis for a note if the note has not changed since they were fetched */
+func (c *noteEmojiCache) get(note *gitlab.Note) ([]*gitlab.AwardEmoji, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[note.ID]
+	if !ok || !entry.updatedAt.Equal(noteUpdatedAt(note)) || time.Since(entry.fetchedAt) > noteEmojiCacheTTL {
+		return nil, false
+	}
+
+	return entry.emojis, true
+}
+
+func (c *noteEmojiCache) set(note *gitlab.Note, emojis []*gitlab.AwardEmoji) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[note.ID] = noteEmojiCacheEntry{
+		updatedAt: noteUpdatedAt(note),
+		fetchedAt: time.Now(),
+		emojis:    emojis,
+	}
+}
+
+/* invalidate drops the cached emojis for a note, used when the user reacts to a note through the plugin */
+func (c *noteEmojiCache) invalidate(noteID int) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, noteID)
+}
+
+func noteUpdatedAt(note *gitlab.Note) time.Time {
+	if note.UpdatedAt == nil {
+		return time.Time{}
+	}
+	return *note.UpdatedAt
+}
@@ -0,0 +1,255 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const defaultEventsPollInterval = 10 * time.Second
+
+type MrEventType string
+
+const (
+	EventReady                 MrEventType = "ready"
+	EventError                 MrEventType = "error"
+	EventNoteCreated           MrEventType = "note_created"
+	EventNoteEdited            MrEventType = "note_edited"
+	EventDiscussionResolved    MrEventType = "discussion_resolved"
+	EventDiscussionUnresolved  MrEventType = "discussion_unresolved"
+	EventApprovalAdded         MrEventType = "approval_added"
+	EventApprovalRevoked       MrEventType = "approval_revoked"
+	EventPipelineStatusChanged MrEventType = "pipeline_status_changed"
+	EventTitleChanged          MrEventType = "title_changed"
+	EventDescriptionChanged    MrEventType = "description_changed"
+)
+
+/* MrEvent is a single change to the merge request. Only the fields relevant to the event type are set. */
+type MrEvent struct {
+	Type           MrEventType  `json:"type"`
+	DiscussionId   string       `json:"discussion_id,omitempty"`
+	Note           *gitlab.Note `json:"note,omitempty"`
+	Username       string       `json:"username,omitempty"`
+	PipelineId     int          `json:"pipeline_id,omitempty"`
+	PipelineStatus string       `json:"pipeline_status,omitempty"`
+	Title          string       `json:"title,omitempty"`
+	Description    string       `json:"description,omitempty"`
+	Message        string       `json:"message,omitempty"`
+}
+
+type EventsPoller interface {
+	DiscussionsLister
+	MergeRequestGetter
+	GetConfiguration(pid interface{}, mr int, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error)
+}
+
+type eventsService struct {
+	data
+	client EventsPoller
+	hub    *eventsHub
+}
+
+/*
+eventsHandler streams changes to the current merge request as Server-Sent Events. The hub polls Gitlab on an
+interval, compares the result against the previous poll, and the handler writes one event per change. The stream
+ends when the client disconnects or the server shuts down.
+*/
+func (a eventsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handleError(w, errors.New("response writer does not support flushing"), "Could not stream events", http.StatusInternalServerError)
+		return
+	}
+
+	mergeId := a.mergeId(r)
+	updates, err := a.hub.subscribe(mergeId, func() (*mrSnapshot, error) { return a.takeSnapshot(mergeId) })
+	if err != nil {
+		handleError(w, err, "Could not stream events", http.StatusInternalServerError)
+		return
+	}
+	defer a.hub.unsubscribe(mergeId, updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeEvent(w, MrEvent{Type: EventReady}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		var events []MrEvent
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.hub.done:
+			return
+		case events, ok = <-updates:
+			if !ok {
+				return
+			}
+		}
+
+		for _, event := range events {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+
+		/* An SSE comment keeps idle connections from being closed by intermediaries */
+		if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event MrEvent) error {
+	j, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, j)
+	return err
+}
+
+type noteSnapshot struct {
+	discussionId string
+	note         *gitlab.Note
+}
+
+/* mrSnapshot is the subset of merge request state we watch for changes */
+type mrSnapshot struct {
+	title          string
+	description    string
+	pipelineId     int
+	pipelineStatus string
+	approvedBy     map[int]string
+	notes          map[int]noteSnapshot
+	resolved       map[string]bool
+}
+
+/* takeSnapshot fetches the merge request, its approvals, and every discussion */
+func (a eventsService) takeSnapshot(mergeId int) (*mrSnapshot, error) {
+	mr, res, err := a.client.GetMergeRequest(a.projectInfo.ProjectId, mergeId, &gitlab.GetMergeRequestsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("getting merge request returned status %d", res.StatusCode)
+	}
+
+	approvals, res, err := a.client.GetConfiguration(a.projectInfo.ProjectId, mergeId)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("getting approvals returned status %d", res.StatusCode)
+	}
+
+	discussions, res, err := discussionsListerService{a.data, a.client}.fetchAllDiscussions(mergeId)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("listing discussions returned status %d", res.StatusCode)
+	}
+
+	snapshot := &mrSnapshot{
+		title:       mr.Title,
+		description: mr.Description,
+		approvedBy:  make(map[int]string),
+		notes:       make(map[int]noteSnapshot),
+		resolved:    make(map[string]bool),
+	}
+
+	if mr.HeadPipeline != nil {
+		snapshot.pipelineId = mr.HeadPipeline.ID
+		snapshot.pipelineStatus = mr.HeadPipeline.Status
+	}
+
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil {
+			snapshot.approvedBy[approver.User.ID] = approver.User.Username
+		}
+	}
+
+	for _, discussion := range discussions {
+		resolvable, resolved := false, true
+		for _, note := range discussion.Notes {
+			if note.System {
+				continue
+			}
+			snapshot.notes[note.ID] = noteSnapshot{discussionId: discussion.ID, note: note}
+			if note.Resolvable {
+				resolvable = true
+				resolved = resolved && note.Resolved
+			}
+		}
+		if resolvable {
+			snapshot.resolved[discussion.ID] = resolved
+		}
+	}
+
+	return snapshot, nil
+}
+
+/* diffSnapshots returns an event for every change between two snapshots */
+func diffSnapshots(prev *mrSnapshot, next *mrSnapshot) []MrEvent {
+	var events []MrEvent
+
+	if prev.title != next.title {
+		events = append(events, MrEvent{Type: EventTitleChanged, Title: next.title})
+	}
+
+	if prev.description != next.description {
+		events = append(events, MrEvent{Type: EventDescriptionChanged, Description: next.description})
+	}
+
+	if prev.pipelineId != next.pipelineId || prev.pipelineStatus != next.pipelineStatus {
+		events = append(events, MrEvent{Type: EventPipelineStatusChanged, PipelineId: next.pipelineId, PipelineStatus: next.pipelineStatus})
+	}
+
+	for id, username := range next.approvedBy {
+		if _, ok := prev.approvedBy[id]; !ok {
+			events = append(events, MrEvent{Type: EventApprovalAdded, Username: username})
+		}
+	}
+
+	for id, username := range prev.approvedBy {
+		if _, ok := next.approvedBy[id]; !ok {
+			events = append(events, MrEvent{Type: EventApprovalRevoked, Username: username})
+		}
+	}
+
+	for id, n := range next.notes {
+		p, ok := prev.notes[id]
+		if !ok {
+			events = append(events, MrEvent{Type: EventNoteCreated, DiscussionId: n.discussionId, Note: n.note})
+			continue
+		}
+		if p.note.Body != n.note.Body {
+			events = append(events, MrEvent{Type: EventNoteEdited, DiscussionId: n.discussionId, Note: n.note})
+		}
+	}
+
+	for id, resolved := range next.resolved {
+		wasResolved, ok := prev.resolved[id]
+		if ok && wasResolved == resolved || !ok && !resolved {
+			continue
+		}
+		eventType := EventDiscussionUnresolved
+		if resolved {
+			eventType = EventDiscussionResolved
+		}
+		events = append(events, MrEvent{Type: eventType, DiscussionId: id})
+	}
+
+	return events
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+/* How many polls a subscriber may fall behind before it is dropped */
+const eventsSubscriberBuffer = 16
+
+/*
+eventsHub runs one poller per merge request and fans its events out to every connection streaming that merge
+request, so that several editors watching the same MR do not each poll Gitlab. A poller is started by the first
+subscriber and stopped once the last one leaves.
+*/
+type eventsHub struct {
+	mu       sync.Mutex
+	pollers  map[int]*mrPoller
+	interval time.Duration
+	done     <-chan struct{}
+}
+
+/* mrPoller polls a single merge request and sends every poll's events, possibly none, to its subscribers */
+type mrPoller struct {
+	take        func() (*mrSnapshot, error)
+	previous    *mrSnapshot
+	subscribers map[chan []MrEvent]struct{}
+	stop        chan struct{}
+}
+
+func newEventsHub(interval time.Duration, done <-chan struct{}) *eventsHub {
+	if interval == 0 {
+		interval = defaultEventsPollInterval
+	}
+	return &eventsHub{pollers: make(map[int]*mrPoller), interval: interval, done: done}
+}
+
+/*
+subscribe returns a channel receiving the events of every poll of the merge request. When nobody is watching the
+merge request yet, the first snapshot is taken before subscribing so that a failure can be reported to the client.
+The channel is closed if the subscriber falls too far behind.
+*/
+func (h *eventsHub) subscribe(mergeId int, take func() (*mrSnapshot, error)) (chan []MrEvent, error) {
+	events := make(chan []MrEvent, eventsSubscriberBuffer)
+
+	h.mu.Lock()
+	if poller, ok := h.pollers[mergeId]; ok {
+		poller.subscribers[events] = struct{}{}
+		h.mu.Unlock()
+		return events, nil
+	}
+	h.mu.Unlock()
+
+	snapshot, err := take()
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	/* Another subscriber may have started a poller while the snapshot was taken */
+	if poller, ok := h.pollers[mergeId]; ok {
+		poller.subscribers[events] = struct{}{}
+		return events, nil
+	}
+
+	poller := &mrPoller{
+		take:        take,
+		previous:    snapshot,
+		subscribers: map[chan []MrEvent]struct{}{events: {}},
+		stop:        make(chan struct{}),
+	}
+	h.pollers[mergeId] = poller
+	go h.run(poller)
+
+	return events, nil
+}
+
+/* unsubscribe stops sending events to the channel, and stops polling once nobody is left */
+func (h *eventsHub) unsubscribe(mergeId int, events chan []MrEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	poller, ok := h.pollers[mergeId]
+	if !ok {
+		return
+	}
+
+	delete(poller.subscribers, events)
+	if len(poller.subscribers) == 0 {
+		delete(h.pollers, mergeId)
+		close(poller.stop)
+	}
+}
+
+func (h *eventsHub) run(poller *mrPoller) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-poller.stop:
+			return
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+
+		events := []MrEvent{}
+		next, err := poller.take()
+		if err != nil {
+			events = append(events, MrEvent{Type: EventError, Message: err.Error()})
+		} else {
+			events = diffSnapshots(poller.previous, next)
+			poller.previous = next
+		}
+
+		h.broadcast(poller, events)
+	}
+}
+
+/* broadcast sends the events to every subscriber, dropping those whose buffer is full */
+func (h *eventsHub) broadcast(poller *mrPoller, events []MrEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for subscriber := range poller.subscribers {
+		select {
+		case subscriber <- events:
+		default:
+			delete(poller.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeEventsPoller struct {
+	fakeDiscussionsLister
+	polls *int32
+}
+
+func (f fakeEventsPoller) GetMergeRequest(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestsOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	poll := atomic.AddInt32(f.polls, 1)
+	return &gitlab.MergeRequest{Title: fmt.Sprintf("Title %d", poll)}, resp, err
+}
+
+func (f fakeEventsPoller) GetConfiguration(pid interface{}, mr int, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gitlab.MergeRequestApprovals{}, resp, err
+}
+
+func TestEventsHandler(t *testing.T) {
+	t.Run("Streams changes to the merge request", func(t *testing.T) {
+		var polls int32
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		request := makeRequest(t, http.MethodGet, "/events", nil).WithContext(ctx)
+		svc := middleware(
+			eventsService{data: testProjectData, client: fakeEventsPoller{fakeDiscussionsLister{pages: 1}, &polls}, hub: newEventsHub(time.Millisecond, nil)},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withMethodCheck(http.MethodGet),
+		)
+		res := httptest.NewRecorder()
+		svc.ServeHTTP(res, request)
+		body := res.Body.String()
+		assert(t, res.Header().Get("Content-Type"), "text/event-stream")
+		assert(t, strings.HasPrefix(body, "event: ready\n"), true)
+		assert(t, strings.Contains(body, "event: title_changed\ndata: {\"type\":\"title_changed\",\"title\":\"Title 2\"}"), true)
+	})
+	t.Run("Stops streaming on shutdown", func(t *testing.T) {
+		var polls int32
+		done := make(chan struct{})
+		close(done)
+		request := makeRequest(t, http.MethodGet, "/events", nil)
+		svc := eventsService{data: testProjectData, client: fakeEventsPoller{fakeDiscussionsLister{pages: 1}, &polls}, hub: newEventsHub(time.Hour, done)}
+		res := httptest.NewRecorder()
+		svc.ServeHTTP(res, request)
+		assert(t, res.Body.String(), "event: ready\ndata: {\"type\":\"ready\"}\n\n")
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		var polls int32
+		request := makeRequest(t, http.MethodGet, "/events", nil)
+		svc := eventsService{data: testProjectData, client: fakeEventsPoller{fakeDiscussionsLister{testBase: testBase{errFromGitlab: true}}, &polls}, hub: newEventsHub(time.Hour, nil)}
+		data, status := getFailData(t, svc, request)
+		checkErrorFromGitlab(t, data, "Could not stream events")
+		assert(t, status, http.StatusInternalServerError)
+	})
+}
+
+func TestEventsHub(t *testing.T) {
+	titledSnapshot := func(polls *int32) func() (*mrSnapshot, error) {
+		return func() (*mrSnapshot, error) {
+			poll := atomic.AddInt32(polls, 1)
+			return &mrSnapshot{title: fmt.Sprintf("Title %d", poll)}, nil
+		}
+	}
+
+	t.Run("Shares one poller between the subscribers of a merge request", func(t *testing.T) {
+		var polls int32
+		hub := newEventsHub(time.Millisecond, nil)
+		first, err := hub.subscribe(1, titledSnapshot(&polls))
+		assert(t, err == nil, true)
+		second, err := hub.subscribe(1, titledSnapshot(&polls))
+		assert(t, err == nil, true)
+		assert(t, atomic.LoadInt32(&polls), int32(1))
+
+		for _, subscriber := range []chan []MrEvent{first, second} {
+			select {
+			case events := <-subscriber:
+				assert(t, events[0], MrEvent{Type: EventTitleChanged, Title: "Title 2"})
+			case <-time.After(time.Second):
+				t.Fatal("no events were sent")
+			}
+		}
+
+		hub.unsubscribe(1, first)
+		hub.unsubscribe(1, second)
+	})
+	t.Run("Stops polling once the last subscriber leaves", func(t *testing.T) {
+		var polls int32
+		hub := newEventsHub(time.Hour, nil)
+		first, _ := hub.subscribe(1, titledSnapshot(&polls))
+		second, _ := hub.subscribe(1, titledSnapshot(&polls))
+		other, _ := hub.subscribe(2, titledSnapshot(&polls))
+		assert(t, len(hub.pollers), 2)
+
+		hub.unsubscribe(1, first)
+		assert(t, len(hub.pollers), 2)
+		poller := hub.pollers[1]
+		hub.unsubscribe(1, second)
+		assert(t, len(hub.pollers), 1)
+		_, open := <-poller.stop
+		assert(t, open, false)
+
+		hub.unsubscribe(2, other)
+		assert(t, len(hub.pollers), 0)
+	})
+	t.Run("Drops subscribers that fall behind", func(t *testing.T) {
+		var polls int32
+		hub := newEventsHub(time.Hour, nil)
+		events, _ := hub.subscribe(1, titledSnapshot(&polls))
+		poller := hub.pollers[1]
+		for i := 0; i <= eventsSubscriberBuffer; i++ {
+			hub.broadcast(poller, []MrEvent{})
+		}
+		assert(t, len(poller.subscribers), 0)
+
+		hub.unsubscribe(1, events)
+		assert(t, len(hub.pollers), 0)
+	})
+	t.Run("Does not start a poller when the first snapshot fails", func(t *testing.T) {
+		hub := newEventsHub(time.Hour, nil)
+		_, err := hub.subscribe(1, func() (*mrSnapshot, error) { return nil, errorFromGitlab })
+		assert(t, err == errorFromGitlab, true)
+		assert(t, len(hub.pollers), 0)
+	})
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	emptySnapshot := func() *mrSnapshot {
+		return &mrSnapshot{
+			approvedBy: map[int]string{},
+			notes:      map[int]noteSnapshot{},
+			resolved:   map[string]bool{},
+		}
+	}
+
+	t.Run("Reports nothing when nothing changed", func(t *testing.T) {
+		assert(t, len(diffSnapshots(emptySnapshot(), emptySnapshot())), 0)
+	})
+	t.Run("Reports new and edited notes", func(t *testing.T) {
+		prev, next := emptySnapshot(), emptySnapshot()
+		prev.notes[1] = noteSnapshot{"abc", &gitlab.Note{ID: 1, Body: "Before"}}
+		next.notes[1] = noteSnapshot{"abc", &gitlab.Note{ID: 1, Body: "After"}}
+		events := diffSnapshots(prev, next)
+		assert(t, len(events), 1)
+		assert(t, events[0].Type, EventNoteEdited)
+
+		prev.notes = map[int]noteSnapshot{}
+		events = diffSnapshots(prev, next)
+		assert(t, len(events), 1)
+		assert(t, events[0].Type, EventNoteCreated)
+		assert(t, events[0].DiscussionId, "abc")
+	})
+	t.Run("Reports resolution changes", func(t *testing.T) {
+		prev, next := emptySnapshot(), emptySnapshot()
+		prev.resolved["abc"] = false
+		next.resolved["abc"] = true
+		events := diffSnapshots(prev, next)
+		assert(t, len(events), 1)
+		assert(t, events[0].Type, EventDiscussionResolved)
+		events = diffSnapshots(next, prev)
+		assert(t, events[0].Type, EventDiscussionUnresolved)
+	})
+	t.Run("Reports approvals and revocations", func(t *testing.T) {
+		prev, next := emptySnapshot(), emptySnapshot()
+		next.approvedBy[1] = "hcramer"
+		events := diffSnapshots(prev, next)
+		assert(t, events[0].Type, EventApprovalAdded)
+		assert(t, events[0].Username, "hcramer")
+		events = diffSnapshots(next, prev)
+		assert(t, events[0].Type, EventApprovalRevoked)
+	})
+	t.Run("Reports pipeline, title and description changes", func(t *testing.T) {
+		prev, next := emptySnapshot(), emptySnapshot()
+		next.pipelineId = 5
+		next.pipelineStatus = "running"
+		next.title = "New title"
+		next.description = "New description"
+		events := diffSnapshots(prev, next)
+		assert(t, len(events), 3)
+		assert(t, events[0].Type, EventTitleChanged)
+		assert(t, events[1].Type, EventDescriptionChanged)
+		assert(t, events[2].Type, EventPipelineStatusChanged)
+		assert(t, events[2].PipelineStatus, "running")
+	})
+}
@@ -0,0 +1,550 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	exportFormatJSON     = "json"
+	exportFormatMarkdown = "markdown"
+
+	/* exportSchemaVersion is bumped whenever a field of the JSON export is changed or removed */
+	exportSchemaVersion = 1
+
+	/* exportHunkContext is how many lines above a comment are shown with it, as Gitlab does */
+	exportHunkContext = 3
+)
+
+type ExportRequest struct {
+	Format string `json:"format" validate:"omitempty,oneof=json markdown"`
+}
+
+type ExportResponse struct {
+	SuccessResponse
+	Format   string    `json:"format"`
+	Export   *MrExport `json:"export,omitempty"`
+	Markdown string    `json:"markdown,omitempty"`
+}
+
+/*
+MrExport is the archived state of a review. Unlike the Gitlab types it is built from, its fields only change
+along with exportSchemaVersion, so that archives can be read back reliably.
+*/
+type MrExport struct {
+	SchemaVersion int                  `json:"schema_version"`
+	ExportedAt    time.Time            `json:"exported_at"`
+	MergeRequest  ExportedMergeRequest `json:"merge_request"`
+	Approvals     ExportedApprovals    `json:"approvals"`
+	Pipeline      *ExportedPipeline    `json:"pipeline"`
+	Discussions   []ExportedDiscussion `json:"discussions"`
+}
+
+type ExportedMergeRequest struct {
+	IID          int        `json:"iid"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	Author       string     `json:"author"`
+	SourceBranch string     `json:"source_branch"`
+	TargetBranch string     `json:"target_branch"`
+	Labels       []string   `json:"labels"`
+	WebURL       string     `json:"web_url"`
+	CreatedAt    *time.Time `json:"created_at"`
+	MergedAt     *time.Time `json:"merged_at"`
+	MergedBy     string     `json:"merged_by,omitempty"`
+}
+
+type ExportedApprovals struct {
+	Approved          bool     `json:"approved"`
+	ApprovalsRequired int      `json:"approvals_required"`
+	ApprovalsLeft     int      `json:"approvals_left"`
+	ApprovedBy        []string `json:"approved_by"`
+}
+
+type ExportedPipeline struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Ref    string `json:"ref"`
+	SHA    string `json:"sha"`
+	WebURL string `json:"web_url"`
+}
+
+type ExportedPosition struct {
+	FileName    string `json:"file_name"`
+	OldFileName string `json:"old_file_name"`
+	NewLine     *int   `json:"new_line"`
+	OldLine     *int   `json:"old_line"`
+	HeadSHA     string `json:"head_sha"`
+	BaseSHA     string `json:"base_sha"`
+}
+
+type ExportedDiscussion struct {
+	ID            string            `json:"id"`
+	Resolvable    bool              `json:"resolvable"`
+	Resolved      bool              `json:"resolved"`
+	Position      *ExportedPosition `json:"position"`
+	DiffHunk      string            `json:"diff_hunk,omitempty"`
+	DiffHunkError string            `json:"diff_hunk_error,omitempty"`
+	Notes         []ExportedNote    `json:"notes"`
+}
+
+type ExportedNote struct {
+	ID         int             `json:"id"`
+	Author     string          `json:"author"`
+	Body       string          `json:"body"`
+	Internal   bool            `json:"internal"`
+	CreatedAt  *time.Time      `json:"created_at"`
+	Resolved   bool            `json:"resolved"`
+	ResolvedBy string          `json:"resolved_by,omitempty"`
+	Emojis     []ExportedEmoji `json:"emojis"`
+	EmojiError string          `json:"emoji_error,omitempty"`
+}
+
+type ExportedEmoji struct {
+	Name string `json:"name"`
+	User string `json:"user"`
+}
+
+type MrExporter interface {
+	DiscussionsLister
+	MergeRequestGetter
+	GetConfiguration(pid interface{}, mr int, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error)
+}
+
+type exportService struct {
+	data
+	client MrExporter
+}
+
+/*
+exportHandler gathers the merge request, its discussions with their replies and emojis, its approvals and its
+latest pipeline into a single report, either as JSON or as a self-contained Markdown document. Diff notes come
+with the lines of the diff they were left on.
+*/
+func (a exportService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := r.Context().Value(payload("payload")).(*ExportRequest)
+	mergeId := a.mergeId(r)
+
+	mr, res, err := a.client.GetMergeRequest(a.projectInfo.ProjectId, mergeId, &gitlab.GetMergeRequestsOptions{})
+	if err != nil {
+		handleError(w, err, "Could not get merge request", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not get merge request", res.StatusCode)
+		return
+	}
+
+	approvals, res, err := a.client.GetConfiguration(a.projectInfo.ProjectId, mergeId)
+	if err != nil {
+		handleError(w, err, "Could not get approvals", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not get approvals", res.StatusCode)
+		return
+	}
+
+	lister := discussionsListerService{a.data, a.client}
+	discussions, res, err := lister.fetchAllDiscussions(mergeId)
+	if err != nil {
+		handleError(w, err, "Could not list discussions", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not list discussions", res.StatusCode)
+		return
+	}
+
+	normalizeInternalNotes(discussions)
+
+	export := &MrExport{
+		SchemaVersion: exportSchemaVersion,
+		ExportedAt:    time.Now().UTC(),
+		MergeRequest:  exportMergeRequest(mr),
+		Approvals:     exportApprovals(approvals),
+		Pipeline:      exportPipeline(mr.HeadPipeline),
+		Discussions:   []ExportedDiscussion{},
+	}
+
+	var notes []*gitlab.Note
+	for _, discussion := range discussions {
+		for _, note := range discussion.Notes {
+			if !note.System {
+				notes = append(notes, note)
+			}
+		}
+	}
+	emojis, emojiErrors := lister.fetchEmojisForNotes(mergeId, notes)
+
+	/* Every diff note is on some version of the diff, comparing its base and head commits gives us its lines */
+	diffs := newPositionTranslator(a.client, a.projectInfo.ProjectId, nil, a.compareCache)
+	for _, discussion := range discussions {
+		exported, ok := exportDiscussion(discussion, emojis, emojiErrors)
+		if !ok {
+			continue
+		}
+		if position := discussion.Notes[0].Position; position != nil {
+			exported.DiffHunk, err = diffHunkForPosition(diffs, position)
+			if err != nil {
+				exported.DiffHunkError = err.Error()
+			}
+		}
+		export.Discussions = append(export.Discussions, exported)
+	}
+
+	sort.SliceStable(export.Discussions, func(i, j int) bool {
+		return noteCreatedBefore(export.Discussions[i].Notes[0], export.Discussions[j].Notes[0])
+	})
+
+	response := ExportResponse{
+		SuccessResponse: SuccessResponse{Message: "Merge request exported"},
+		Format:          payload.Format,
+	}
+
+	if payload.Format == exportFormatMarkdown {
+		response.Markdown = renderExportMarkdown(export)
+	} else {
+		response.Format = exportFormatJSON
+		response.Export = export
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+func exportMergeRequest(mr *gitlab.MergeRequest) ExportedMergeRequest {
+	exported := ExportedMergeRequest{
+		IID:          mr.IID,
+		Title:        mr.Title,
+		Description:  mr.Description,
+		State:        mr.State,
+		SourceBranch: mr.SourceBranch,
+		TargetBranch: mr.TargetBranch,
+		Labels:       []string{},
+		WebURL:       mr.WebURL,
+		CreatedAt:    mr.CreatedAt,
+		MergedAt:     mr.MergedAt,
+	}
+	if mr.Author != nil {
+		exported.Author = mr.Author.Username
+	}
+	if mr.MergedBy != nil {
+		exported.MergedBy = mr.MergedBy.Username
+	}
+	exported.Labels = append(exported.Labels, mr.Labels...)
+	return exported
+}
+
+func exportApprovals(approvals *gitlab.MergeRequestApprovals) ExportedApprovals {
+	exported := ExportedApprovals{
+		Approved:          approvals.Approved,
+		ApprovalsRequired: approvals.ApprovalsRequired,
+		ApprovalsLeft:     approvals.ApprovalsLeft,
+		ApprovedBy:        []string{},
+	}
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil {
+			exported.ApprovedBy = append(exported.ApprovedBy, approver.User.Username)
+		}
+	}
+	return exported
+}
+
+func exportPipeline(pipeline *gitlab.Pipeline) *ExportedPipeline {
+	if pipeline == nil {
+		return nil
+	}
+	return &ExportedPipeline{
+		ID:     pipeline.ID,
+		Status: pipeline.Status,
+		Ref:    pipeline.Ref,
+		SHA:    pipeline.SHA,
+		WebURL: pipeline.WebURL,
+	}
+}
+
+/* exportDiscussion converts a discussion, leaving out system notes. Discussions with only system notes are skipped. */
+func exportDiscussion(discussion *gitlab.Discussion, emojis map[int][]*gitlab.AwardEmoji, emojiErrors map[int]string) (ExportedDiscussion, bool) {
+	exported := ExportedDiscussion{ID: discussion.ID}
+	exported.Resolvable, exported.Resolved = discussionResolution(discussion)
+
+	for _, note := range discussion.Notes {
+		if note.System {
+			continue
+		}
+		exportedNote := ExportedNote{
+			ID:         note.ID,
+			Author:     note.Author.Username,
+			Body:       note.Body,
+			Internal:   note.Internal,
+			CreatedAt:  note.CreatedAt,
+			Resolved:   note.Resolved,
+			ResolvedBy: note.ResolvedBy.Username,
+			Emojis:     []ExportedEmoji{},
+			EmojiError: emojiErrors[note.ID],
+		}
+		for _, emoji := range emojis[note.ID] {
+			exportedNote.Emojis = append(exportedNote.Emojis, ExportedEmoji{Name: emoji.Name, User: emoji.User.Username})
+		}
+		exported.Notes = append(exported.Notes, exportedNote)
+	}
+
+	if len(exported.Notes) == 0 {
+		return exported, false
+	}
+
+	if position := discussion.Notes[0].Position; position != nil {
+		exported.Position = &ExportedPosition{
+			FileName:    position.NewPath,
+			OldFileName: position.OldPath,
+			HeadSHA:     position.HeadSHA,
+			BaseSHA:     position.BaseSHA,
+		}
+		if position.NewLine != 0 {
+			exported.Position.NewLine = gitlab.Ptr(position.NewLine)
+		}
+		if position.OldLine != 0 {
+			exported.Position.OldLine = gitlab.Ptr(position.OldLine)
+		}
+	}
+
+	return exported, true
+}
+
+func noteCreatedBefore(a ExportedNote, b ExportedNote) bool {
+	if a.CreatedAt == nil || b.CreatedAt == nil {
+		return a.CreatedAt != nil
+	}
+	return a.CreatedAt.Before(*b.CreatedAt)
+}
+
+/*
+diffHunkForPosition returns the lines of the diff a note was left on, from the start of its line range (or the
+line itself) with a few lines of context above, down to the line the note is on. Notes on whole files or
+images have no lines to show.
+*/
+func diffHunkForPosition(diffs *positionTranslator, position *gitlab.NotePosition) (string, error) {
+	if position.PositionType != "" && position.PositionType != positionTypeText {
+		return "", nil
+	}
+	if position.NewLine == 0 && position.OldLine == 0 {
+		return "", nil
+	}
+
+	changes, err := diffs.fileChanges(position.BaseSHA, position.HeadSHA, position.OldPath)
+	if err != nil {
+		return "", err
+	}
+	if changes.tooLarge {
+		return "", fmt.Errorf("the changes to %s are too large to show", position.OldPath)
+	}
+
+	startOld, startNew := position.OldLine, position.NewLine
+	if lineRange := position.LineRange; lineRange != nil && lineRange.StartRange != nil {
+		startOld, startNew = lineRange.StartRange.OldLine, lineRange.StartRange.NewLine
+	}
+
+	for _, hunk := range changes.hunks {
+		end := findDiffLine(hunk, position.OldLine, position.NewLine)
+		if end < 0 {
+			continue
+		}
+		start := findDiffLine(hunk, startOld, startNew)
+		if start < 0 || start > end {
+			start = end
+		}
+		start -= exportHunkContext
+		if start < 0 {
+			start = 0
+		}
+		return formatDiffExcerpt(hunk, start, end), nil
+	}
+
+	/* Unchanged lines outside of every hunk are not part of the diff Gitlab shows */
+	return "", nil
+}
+
+/* findDiffLine returns the index of the line in the hunk, preferring the new side as Gitlab positions do */
+func findDiffLine(hunk diffHunk, oldLine int, newLine int) int {
+	for i, l := range hunk.lines {
+		if newLine != 0 && l.kind != '-' && l.newLine == newLine {
+			return i
+		}
+		if newLine == 0 && oldLine != 0 && l.kind != '+' && l.oldLine == oldLine {
+			return i
+		}
+	}
+	return -1
+}
+
+/* formatDiffExcerpt writes lines start through end of a hunk as a unified diff hunk of their own */
+func formatDiffExcerpt(hunk diffHunk, start int, end int) string {
+	oldStart, newStart := hunk.oldStart, hunk.newStart
+	for _, l := range hunk.lines[:start] {
+		if l.kind != '+' {
+			oldStart++
+		}
+		if l.kind != '-' {
+			newStart++
+		}
+	}
+
+	oldLines, newLines := 0, 0
+	var body strings.Builder
+	for _, l := range hunk.lines[start : end+1] {
+		if l.kind != '+' {
+			oldLines++
+		}
+		if l.kind != '-' {
+			newLines++
+		}
+		body.WriteByte(l.kind)
+		body.WriteString(l.text)
+		body.WriteString("\n")
+	}
+
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldLines, newStart, newLines) + body.String()
+}
+
+/* renderExportMarkdown writes the export as a Markdown document that reads well without Gitlab at hand */
+func renderExportMarkdown(export *MrExport) string {
+	var s strings.Builder
+	mr := export.MergeRequest
+
+	fmt.Fprintf(&s, "# !%d %s\n\n", mr.IID, mr.Title)
+	fmt.Fprintf(&s, "- **Author:** @%s\n", mr.Author)
+	fmt.Fprintf(&s, "- **State:** %s\n", mr.State)
+	fmt.Fprintf(&s, "- **Branches:** `%s` → `%s`\n", mr.SourceBranch, mr.TargetBranch)
+	if mr.CreatedAt != nil {
+		fmt.Fprintf(&s, "- **Created:** %s\n", formatExportTime(mr.CreatedAt))
+	}
+	if mr.MergedAt != nil {
+		fmt.Fprintf(&s, "- **Merged:** %s by @%s\n", formatExportTime(mr.MergedAt), mr.MergedBy)
+	}
+	if len(mr.Labels) > 0 {
+		fmt.Fprintf(&s, "- **Labels:** %s\n", strings.Join(mr.Labels, ", "))
+	}
+	if mr.WebURL != "" {
+		fmt.Fprintf(&s, "- **URL:** %s\n", mr.WebURL)
+	}
+	fmt.Fprintf(&s, "- **Exported:** %s\n", formatExportTime(&export.ExportedAt))
+
+	if mr.Description != "" {
+		fmt.Fprintf(&s, "\n## Description\n\n%s\n", strings.TrimRight(mr.Description, "\n"))
+	}
+
+	s.WriteString("\n## Approvals\n\n")
+	approved := "no"
+	if export.Approvals.Approved {
+		approved = "yes"
+	}
+	fmt.Fprintf(&s, "Approved: %s (%d required, %d left)\n", approved, export.Approvals.ApprovalsRequired, export.Approvals.ApprovalsLeft)
+	if len(export.Approvals.ApprovedBy) > 0 {
+		s.WriteString("\n")
+		for _, approver := range export.Approvals.ApprovedBy {
+			fmt.Fprintf(&s, "- @%s\n", approver)
+		}
+	}
+
+	s.WriteString("\n## Pipeline\n\n")
+	if pipeline := export.Pipeline; pipeline != nil {
+		fmt.Fprintf(&s, "#%d **%s** on `%s` (%s)", pipeline.ID, pipeline.Status, pipeline.Ref, shortSHA(pipeline.SHA))
+		if pipeline.WebURL != "" {
+			fmt.Fprintf(&s, " %s", pipeline.WebURL)
+		}
+		s.WriteString("\n")
+	} else {
+		s.WriteString("No pipeline\n")
+	}
+
+	resolved := 0
+	for _, discussion := range export.Discussions {
+		if discussion.Resolved {
+			resolved++
+		}
+	}
+	fmt.Fprintf(&s, "\n## Discussions (%d, %d resolved)\n", len(export.Discussions), resolved)
+
+	for _, discussion := range export.Discussions {
+		s.WriteString("\n### ")
+		if position := discussion.Position; position != nil {
+			s.WriteString("`" + position.FileName)
+			if position.NewLine != nil {
+				fmt.Fprintf(&s, ":%d", *position.NewLine)
+			} else if position.OldLine != nil {
+				fmt.Fprintf(&s, ":%d (old)", *position.OldLine)
+			}
+			s.WriteString("`")
+		} else {
+			s.WriteString("General")
+		}
+		switch {
+		case discussion.Resolved:
+			s.WriteString(" (resolved)")
+		case discussion.Resolvable:
+			s.WriteString(" (unresolved)")
+		}
+		s.WriteString("\n\n")
+
+		if discussion.DiffHunk != "" {
+			fence := "```"
+			if n := longestBacktickRun(discussion.DiffHunk); n >= len(fence) {
+				fence = strings.Repeat("`", n+1)
+			}
+			fmt.Fprintf(&s, "%sdiff\n%s%s\n\n", fence, discussion.DiffHunk, fence)
+		} else if discussion.DiffHunkError != "" {
+			fmt.Fprintf(&s, "_Could not show the diff: %s_\n\n", discussion.DiffHunkError)
+		}
+
+		for i, note := range discussion.Notes {
+			if i > 0 {
+				s.WriteString("\n")
+			}
+			internal := ""
+			if note.Internal {
+				internal = " (internal)"
+			}
+			fmt.Fprintf(&s, "**@%s** %s%s\n\n", note.Author, formatExportTime(note.CreatedAt), internal)
+			for _, line := range strings.Split(strings.TrimRight(note.Body, "\n"), "\n") {
+				s.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+			}
+			if len(note.Emojis) > 0 {
+				var reactions []string
+				for _, emoji := range note.Emojis {
+					reactions = append(reactions, fmt.Sprintf(":%s: @%s", emoji.Name, emoji.User))
+				}
+				fmt.Fprintf(&s, "\nReactions: %s\n", strings.Join(reactions, ", "))
+			}
+		}
+	}
+
+	return s.String()
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04 MST")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
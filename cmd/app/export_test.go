@@ -0,0 +1,135 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeMrExporter struct {
+	fakeDiscussionsLister
+}
+
+func (f fakeMrExporter) GetMergeRequest(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestsOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mr := &gitlab.MergeRequest{IID: 3, Title: "Add export", State: "opened", SourceBranch: "feature", TargetBranch: "main"}
+	mr.Author = &gitlab.BasicUser{Username: "hcramer"}
+	mr.HeadPipeline = &gitlab.Pipeline{ID: 12, Status: "success", Ref: "feature", SHA: "0123456789abcdef"}
+	return mr, resp, err
+}
+
+func (f fakeMrExporter) GetConfiguration(pid interface{}, mr int, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gitlab.MergeRequestApprovals{
+		Approved:          true,
+		ApprovalsRequired: 1,
+		ApprovedBy:        []*gitlab.MergeRequestApproverUser{{User: &gitlab.BasicUser{Username: "reviewer"}}},
+	}, resp, err
+}
+
+func getExport(t *testing.T, svc http.Handler, request *http.Request) ExportResponse {
+	res := httptest.NewRecorder()
+	svc.ServeHTTP(res, request)
+
+	var data ExportResponse
+	err := json.Unmarshal(res.Body.Bytes(), &data)
+	if err != nil {
+		t.Error(err)
+	}
+	return data
+}
+
+func TestExportHandler(t *testing.T) {
+	exportService := func(client MrExporter) http.HandlerFunc {
+		return middleware(
+			exportService{testProjectData, client},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ExportRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+	}
+
+	t.Run("Exports the review as JSON", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/export", ExportRequest{})
+		data := getExport(t, exportService(fakeMrExporter{fakeDiscussionsLister{withPositions: true}}), request)
+		assert(t, data.Message, "Merge request exported")
+		assert(t, data.Format, "json")
+		assert(t, data.Export.SchemaVersion, 1)
+		assert(t, data.Export.MergeRequest.Title, "Add export")
+		assert(t, data.Export.Approvals.ApprovedBy[0], "reviewer")
+		assert(t, data.Export.Pipeline.Status, "success")
+		assert(t, len(data.Export.Discussions), 3)
+		assert(t, data.Export.Discussions[0].ID, "current")
+		assert(t, *data.Export.Discussions[0].Position.NewLine, 3)
+		assert(t, data.Export.Discussions[0].DiffHunk, "@@ -1,2 +1,3 @@\n a\n+new\n b\n")
+	})
+	t.Run("Exports the review as Markdown", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/export", ExportRequest{Format: "markdown"})
+		data := getExport(t, exportService(fakeMrExporter{fakeDiscussionsLister{withPositions: true}}), request)
+		assert(t, data.Format, "markdown")
+		assert(t, data.Export == nil, true)
+		assert(t, strings.HasPrefix(data.Markdown, "# !3 Add export\n"), true)
+		assert(t, strings.Contains(data.Markdown, "- @reviewer\n"), true)
+		assert(t, strings.Contains(data.Markdown, "#12 **success** on `feature` (01234567)"), true)
+		assert(t, strings.Contains(data.Markdown, "### `main.go:3`\n\n```diff\n@@ -1,2 +1,3 @@\n a\n+new\n b\n```\n"), true)
+	})
+	t.Run("Rejects unknown formats", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/export", ExportRequest{Format: "pdf"})
+		_, status := getFailData(t, exportService(fakeMrExporter{}), request)
+		assert(t, status, http.StatusBadRequest)
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/export", ExportRequest{})
+		data, status := getFailData(t, exportService(fakeMrExporter{fakeDiscussionsLister{testBase: testBase{errFromGitlab: true}}}), request)
+		assert(t, status, http.StatusInternalServerError)
+		checkErrorFromGitlab(t, data, "Could not get merge request")
+	})
+	t.Run("Handles non-200s from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/export", ExportRequest{})
+		data, _ := getFailData(t, exportService(fakeMrExporter{fakeDiscussionsLister{testBase: testBase{status: http.StatusSeeOther}}}), request)
+		checkNon200(t, data, "Could not get merge request", "/mr/export")
+	})
+}
+
+func TestDiffHunkForPosition(t *testing.T) {
+	diffs := newPositionTranslator(fakeDiscussionsLister{}, "1", nil, nil)
+	position := func(oldLine int, newLine int) *gitlab.NotePosition {
+		return &gitlab.NotePosition{OldPath: "main.go", NewPath: "main.go", BaseSHA: "base", HeadSHA: "head", PositionType: "text", OldLine: oldLine, NewLine: newLine}
+	}
+
+	t.Run("Shows removed lines by their old line", func(t *testing.T) {
+		hunk, err := diffHunkForPosition(diffs, position(3, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, hunk, "@@ -1,3 +1,3 @@\n a\n+new\n b\n-c\n")
+	})
+	t.Run("Starts at the beginning of a line range", func(t *testing.T) {
+		p := position(0, 4)
+		p.LineRange = &gitlab.LineRange{StartRange: &gitlab.LinePosition{NewLine: 4}}
+		hunk, err := diffHunkForPosition(diffs, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, hunk, "@@ -2,2 +2,3 @@\n+new\n b\n-c\n+C\n")
+	})
+	t.Run("Skips lines outside of the diff", func(t *testing.T) {
+		hunk, err := diffHunkForPosition(diffs, position(0, 40))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, hunk, "")
+	})
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"github.com/xanzy/go-gitlab"
+)
+
+/*
+CommitsService extends the library's commits service with the merge request level helpers the server needs. The
+library only returns one page at a time, these follow every page so handlers always see the whole merge request.
+*/
+type CommitsService struct {
+	*gitlab.CommitsService
+	client *gitlab.Client
+}
+
+/* ListAllMergeRequestCommits lists every commit of the merge request, newest first as Gitlab returns them */
+func (s *CommitsService) ListAllMergeRequestCommits(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) ([]*gitlab.Commit, *gitlab.Response, error) {
+	var commits []*gitlab.Commit
+	opt := &gitlab.GetMergeRequestCommitsOptions{Page: 1, PerPage: commitsPerPage}
+	for {
+		page, res, err := s.client.MergeRequests.GetMergeRequestCommits(pid, mergeRequest, opt, options...)
+		if err != nil || res.StatusCode >= 300 {
+			return nil, res, err
+		}
+
+		commits = append(commits, page...)
+		if res.NextPage == 0 {
+			return commits, res, nil
+		}
+		opt.Page = res.NextPage
+	}
+}
+
+/* GetAllCommitDiffs returns the changes a commit made to every file, across all pages */
+func (s *CommitsService) GetAllCommitDiffs(pid interface{}, sha string, options ...gitlab.RequestOptionFunc) ([]*gitlab.Diff, *gitlab.Response, error) {
+	var diffs []*gitlab.Diff
+	opt := &gitlab.GetCommitDiffOptions{ListOptions: gitlab.ListOptions{Page: 1, PerPage: commitsPerPage}}
+	for {
+		page, res, err := s.GetCommitDiff(pid, sha, opt, options...)
+		if err != nil || res.StatusCode >= 300 {
+			return nil, res, err
+		}
+
+		diffs = append(diffs, page...)
+		if res.NextPage == 0 {
+			return diffs, res, nil
+		}
+		opt.Page = res.NextPage
+	}
+}
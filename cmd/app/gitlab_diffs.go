@@ -0,0 +1,109 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+/*
+DiffsService reads file diffs straight from Gitlab's API. The go-gitlab library decodes diffs into types that
+drop the flags Gitlab sets on files it would not render in full, so the diffs are decoded into our own type.
+*/
+type DiffsService struct {
+	client *gitlab.Client
+}
+
+/*
+DiffFile is the diff of a single file. A file that is too large or collapsed comes without its diff text, and
+Binary is worked out from the diff text, since Gitlab does not report it.
+*/
+type DiffFile struct {
+	OldPath       string `json:"old_path"`
+	NewPath       string `json:"new_path"`
+	AMode         string `json:"a_mode"`
+	BMode         string `json:"b_mode"`
+	Diff          string `json:"diff"`
+	NewFile       bool   `json:"new_file"`
+	RenamedFile   bool   `json:"renamed_file"`
+	DeletedFile   bool   `json:"deleted_file"`
+	GeneratedFile bool   `json:"generated_file"`
+	TooLarge      bool   `json:"too_large"`
+	Collapsed     bool   `json:"collapsed"`
+	Binary        bool   `json:"binary"`
+}
+
+/* DiffVersionFiles is a version of the merge request along with the diffs of its files */
+type DiffVersionFiles struct {
+	gitlab.MergeRequestDiffVersion
+	Diffs []*DiffFile `json:"diffs"`
+}
+
+/* ListMergeRequestDiffFiles lists one page of the diffs of the merge request's latest version */
+func (s *DiffsService) ListMergeRequestDiffFiles(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiffsOptions, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error) {
+	u := fmt.Sprintf("projects/%s/merge_requests/%d/diffs", gitlab.PathEscape(fmt.Sprint(pid)), mergeRequest)
+
+	req, err := s.client.NewRequest(http.MethodGet, u, opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var diffs []*DiffFile
+	resp, err := s.client.Do(req, &diffs)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	markBinaryDiffs(diffs)
+	return diffs, resp, nil
+}
+
+/* GetMergeRequestDiffVersionFiles gets a single version of the merge request, with the diffs of all of its files */
+func (s *DiffsService) GetMergeRequestDiffVersionFiles(pid interface{}, mergeRequest int, version int, options ...gitlab.RequestOptionFunc) (*DiffVersionFiles, *gitlab.Response, error) {
+	u := fmt.Sprintf("projects/%s/merge_requests/%d/versions/%d", gitlab.PathEscape(fmt.Sprint(pid)), mergeRequest, version)
+
+	req, err := s.client.NewRequest(http.MethodGet, u, nil, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	versionFiles := new(DiffVersionFiles)
+	resp, err := s.client.Do(req, versionFiles)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	markBinaryDiffs(versionFiles.Diffs)
+	return versionFiles, resp, nil
+}
+
+/* CompareDiffFiles returns the diffs of every file changed between two commits */
+func (s *DiffsService) CompareDiffFiles(pid interface{}, from string, to string, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error) {
+	u := fmt.Sprintf("projects/%s/repository/compare", gitlab.PathEscape(fmt.Sprint(pid)))
+	opt := &gitlab.CompareOptions{From: gitlab.Ptr(from), To: gitlab.Ptr(to), Straight: gitlab.Ptr(true)}
+
+	req, err := s.client.NewRequest(http.MethodGet, u, opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var compare struct {
+		Diffs []*DiffFile `json:"diffs"`
+	}
+	resp, err := s.client.Do(req, &compare)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	markBinaryDiffs(compare.Diffs)
+	return compare.Diffs, resp, nil
+}
+
+/* markBinaryDiffs flags the files whose diff is git's placeholder for binary content */
+func markBinaryDiffs(diffs []*DiffFile) {
+	for _, diff := range diffs {
+		diff.Binary = strings.HasPrefix(diff.Diff, "Binary files ") || strings.Contains(diff.Diff, "GIT binary patch")
+	}
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+/*
+DraftNotesService extends the library's draft notes service. The library's DraftNote type has no field for
+internal notes, so listing decodes the draft notes into our own type to keep the flag.
+*/
+type DraftNotesService struct {
+	*gitlab.DraftNotesService
+	client *gitlab.Client
+}
+
+/* DraftNoteWithInternal is a draft note along with whether it will be published as an internal note */
+type DraftNoteWithInternal struct {
+	gitlab.DraftNote
+	Internal bool `json:"internal"`
+}
+
+/* ListDraftNotesWithInternal lists the current user's draft notes on the merge request */
+func (s *DraftNotesService) ListDraftNotesWithInternal(pid interface{}, mergeRequest int, opt *gitlab.ListDraftNotesOptions, options ...gitlab.RequestOptionFunc) ([]*DraftNoteWithInternal, *gitlab.Response, error) {
+	u := fmt.Sprintf("projects/%s/merge_requests/%d/draft_notes", gitlab.PathEscape(fmt.Sprint(pid)), mergeRequest)
+
+	req, err := s.client.NewRequest(http.MethodGet, u, opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var draftNotes []*DraftNoteWithInternal
+	resp, err := s.client.Do(req, &draftNotes)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return draftNotes, resp, nil
+}
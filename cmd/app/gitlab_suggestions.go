@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+/*
+SuggestionsService covers Gitlab's suggestions API, which the go-gitlab library does not implement. It is
+embedded in the Client alongside the services from the library.
+*/
+type SuggestionsService struct {
+	client *gitlab.Client
+}
+
+/* Suggestion is a single ```suggestion block on a diff note, as Gitlab stores it */
+type Suggestion struct {
+	ID          int    `json:"id"`
+	FromLine    int    `json:"from_line"`
+	ToLine      int    `json:"to_line"`
+	Appliable   bool   `json:"appliable"`
+	Applied     bool   `json:"applied"`
+	FromContent string `json:"from_content"`
+	ToContent   string `json:"to_content"`
+}
+
+/* SuggestionNote is the part of a discussion note needed to find its suggestions */
+type SuggestionNote struct {
+	ID          int                  `json:"id"`
+	Body        string               `json:"body"`
+	System      bool                 `json:"system"`
+	Author      *gitlab.BasicUser    `json:"author"`
+	Position    *gitlab.NotePosition `json:"position"`
+	Suggestions []*Suggestion        `json:"suggestions"`
+}
+
+type SuggestionDiscussion struct {
+	ID    string            `json:"id"`
+	Notes []*SuggestionNote `json:"notes"`
+}
+
+/*
+ListMergeRequestDiscussionSuggestions lists the discussions on a merge request, keeping the suggestions Gitlab
+attaches to diff notes. The gitlab.Note type drops them, so the discussions are decoded into our own types.
+*/
+func (s *SuggestionsService) ListMergeRequestDiscussionSuggestions(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiscussionsOptions, options ...gitlab.RequestOptionFunc) ([]*SuggestionDiscussion, *gitlab.Response, error) {
+	u := fmt.Sprintf("projects/%s/merge_requests/%d/discussions", gitlab.PathEscape(fmt.Sprint(pid)), mergeRequest)
+
+	req, err := s.client.NewRequest(http.MethodGet, u, opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var discussions []*SuggestionDiscussion
+	resp, err := s.client.Do(req, &discussions)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return discussions, resp, nil
+}
+
+type ApplySuggestionOptions struct {
+	CommitMessage *string `url:"commit_message,omitempty" json:"commit_message,omitempty"`
+}
+
+/* ApplySuggestion commits a single suggestion to the source branch */
+func (s *SuggestionsService) ApplySuggestion(suggestion int, opt *ApplySuggestionOptions, options ...gitlab.RequestOptionFunc) (*Suggestion, *gitlab.Response, error) {
+	u := fmt.Sprintf("suggestions/%d/apply", suggestion)
+
+	req, err := s.client.NewRequest(http.MethodPut, u, opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied := new(Suggestion)
+	resp, err := s.client.Do(req, applied)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return applied, resp, nil
+}
+
+type ApplyMultipleSuggestionsOptions struct {
+	IDs           []int   `url:"ids" json:"ids"`
+	CommitMessage *string `url:"commit_message,omitempty" json:"commit_message,omitempty"`
+}
+
+/* ApplyMultipleSuggestions commits several suggestions to the source branch in a single commit */
+func (s *SuggestionsService) ApplyMultipleSuggestions(opt *ApplyMultipleSuggestionsOptions, options ...gitlab.RequestOptionFunc) ([]*Suggestion, *gitlab.Response, error) {
+	req, err := s.client.NewRequest(http.MethodPut, "suggestions/batch_apply", opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var applied []*Suggestion
+	resp, err := s.client.Do(req, &applied)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return applied, resp, nil
+}
@@ -23,7 +23,7 @@ type infoService struct {
 
 /* infoHandler fetches infomation about the current git project. The data returned here is used in many other API calls */
 func (a infoService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	mr, res, err := a.client.GetMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, &gitlab.GetMergeRequestsOptions{})
+	mr, res, err := a.client.GetMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.GetMergeRequestsOptions{})
 	if err != nil {
 		handleError(w, err, "Could not get project info", http.StatusInternalServerError)
 		return
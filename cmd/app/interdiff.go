@@ -0,0 +1,217 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+/*
+InterdiffRequest compares two versions of the merge request by their head commits. Without ToVersionID the
+latest version is used, so FromVersionID alone shows what was pushed since that version was reviewed.
+*/
+type InterdiffRequest struct {
+	FromVersionID int `json:"from_version_id" validate:"required,min=1"`
+	ToVersionID   int `json:"to_version_id" validate:"omitempty,min=1"`
+}
+
+type InterdiffLine struct {
+	Type    string `json:"type"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Text    string `json:"text"`
+}
+
+type InterdiffHunk struct {
+	Header   string          `json:"header"`
+	OldStart int             `json:"old_start"`
+	OldLines int             `json:"old_lines"`
+	NewStart int             `json:"new_start"`
+	NewLines int             `json:"new_lines"`
+	Lines    []InterdiffLine `json:"lines"`
+}
+
+/* InterdiffFile is a file that changed between the two versions. Files Gitlab did not send a diff for have no hunks. */
+type InterdiffFile struct {
+	OldPath     string          `json:"old_path"`
+	NewPath     string          `json:"new_path"`
+	NewFile     bool            `json:"new_file"`
+	RenamedFile bool            `json:"renamed_file"`
+	DeletedFile bool            `json:"deleted_file"`
+	Binary      bool            `json:"binary"`
+	TooLarge    bool            `json:"too_large"`
+	Collapsed   bool            `json:"collapsed"`
+	Additions   int             `json:"additions"`
+	Deletions   int             `json:"deletions"`
+	Hunks       []InterdiffHunk `json:"hunks"`
+	HunksError  string          `json:"hunks_error,omitempty"`
+}
+
+/*
+InterdiffResponse lists what changed between the heads of the two versions. When the source branch was rebased
+between them, only files that are part of either version of the merge request are kept, so that changes
+brought in from the target branch do not show up. Those files may still contain such changes.
+*/
+type InterdiffResponse struct {
+	SuccessResponse
+	FromVersion  *gitlab.MergeRequestDiffVersion `json:"from_version"`
+	ToVersion    *gitlab.MergeRequestDiffVersion `json:"to_version"`
+	Rebased      bool                            `json:"rebased"`
+	SkippedFiles int                             `json:"skipped_files"`
+	Files        []InterdiffFile                 `json:"files"`
+}
+
+type InterdiffGetter interface {
+	RevisionsGetter
+	GetMergeRequestDiffVersionFiles(pid interface{}, mergeRequest int, version int, options ...gitlab.RequestOptionFunc) (*DiffVersionFiles, *gitlab.Response, error)
+	CompareDiffFiles(pid interface{}, from string, to string, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error)
+}
+
+type interdiffService struct {
+	data
+	client InterdiffGetter
+}
+
+/* interdiffHandler shows what changed between two versions of the merge request, so a re-review can skip the rest */
+func (a interdiffService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := r.Context().Value(payload("payload")).(*InterdiffRequest)
+	mergeId := a.mergeId(r)
+
+	versions, res, err := a.client.GetMergeRequestDiffVersions(a.projectInfo.ProjectId, mergeId, &gitlab.GetMergeRequestDiffVersionsOptions{})
+	if err != nil {
+		handleError(w, err, "Could not get diff version info", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not get diff version info", res.StatusCode)
+		return
+	}
+
+	fromVersion := findDiffVersion(versions, payload.FromVersionID)
+	if fromVersion == nil {
+		handleError(w, fmt.Errorf("version %d is not a version of merge request !%d", payload.FromVersionID, mergeId), "Could not find diff version", http.StatusNotFound)
+		return
+	}
+
+	var toVersion *gitlab.MergeRequestDiffVersion
+	if payload.ToVersionID != 0 {
+		toVersion = findDiffVersion(versions, payload.ToVersionID)
+	} else {
+		/* Gitlab lists versions newest first */
+		toVersion = versions[0]
+	}
+	if toVersion == nil {
+		handleError(w, fmt.Errorf("version %d is not a version of merge request !%d", payload.ToVersionID, mergeId), "Could not find diff version", http.StatusNotFound)
+		return
+	}
+
+	diffs, res, err := a.client.CompareDiffFiles(a.projectInfo.ProjectId, fromVersion.HeadCommitSHA, toVersion.HeadCommitSHA)
+	if err != nil {
+		handleError(w, err, "Could not compare diff versions", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not compare diff versions", res.StatusCode)
+		return
+	}
+
+	response := InterdiffResponse{
+		SuccessResponse: SuccessResponse{Message: "Interdiff retrieved"},
+		FromVersion:     fromVersion,
+		ToVersion:       toVersion,
+		Rebased:         fromVersion.BaseCommitSHA != toVersion.BaseCommitSHA,
+		Files:           []InterdiffFile{},
+	}
+
+	var reviewedPaths map[string]bool
+	if response.Rebased {
+		reviewedPaths = make(map[string]bool)
+		for _, version := range []*gitlab.MergeRequestDiffVersion{fromVersion, toVersion} {
+			versionFiles, res, err := a.client.GetMergeRequestDiffVersionFiles(a.projectInfo.ProjectId, mergeId, version.ID)
+			if err != nil {
+				handleError(w, err, "Could not get diffs", http.StatusInternalServerError)
+				return
+			}
+
+			if res.StatusCode >= 300 {
+				handleError(w, GenericError{r.URL.Path}, "Could not get diffs", res.StatusCode)
+				return
+			}
+
+			for _, diff := range versionFiles.Diffs {
+				reviewedPaths[diff.OldPath] = true
+				reviewedPaths[diff.NewPath] = true
+			}
+		}
+	}
+
+	for _, diff := range diffs {
+		if reviewedPaths != nil && !reviewedPaths[diff.OldPath] && !reviewedPaths[diff.NewPath] {
+			response.SkippedFiles++
+			continue
+		}
+		response.Files = append(response.Files, buildInterdiffFile(diff))
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/* buildInterdiffFile splits a file's diff into hunks. A diff that cannot be read keeps the file, without hunks. */
+func buildInterdiffFile(diff *DiffFile) InterdiffFile {
+	file := InterdiffFile{
+		OldPath:     diff.OldPath,
+		NewPath:     diff.NewPath,
+		NewFile:     diff.NewFile,
+		RenamedFile: diff.RenamedFile,
+		DeletedFile: diff.DeletedFile,
+		Binary:      diff.Binary,
+		TooLarge:    diff.TooLarge,
+		Collapsed:   diff.Collapsed,
+		Hunks:       []InterdiffHunk{},
+	}
+
+	hunks, err := parseDiffHunks(diff.Diff)
+	if err != nil {
+		file.HunksError = err.Error()
+		return file
+	}
+
+	for _, hunk := range hunks {
+		interdiffHunk := InterdiffHunk{
+			Header:   fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.oldStart, hunk.oldLines, hunk.newStart, hunk.newLines),
+			OldStart: hunk.oldStart,
+			OldLines: hunk.oldLines,
+			NewStart: hunk.newStart,
+			NewLines: hunk.newLines,
+			Lines:    make([]InterdiffLine, 0, len(hunk.lines)),
+		}
+
+		for _, l := range hunk.lines {
+			line := InterdiffLine{OldLine: l.oldLine, NewLine: l.newLine, Text: l.text}
+			switch l.kind {
+			case '+':
+				line.Type = "added"
+				file.Additions++
+			case '-':
+				line.Type = "removed"
+				file.Deletions++
+			default:
+				line.Type = "context"
+			}
+			interdiffHunk.Lines = append(interdiffHunk.Lines, line)
+		}
+
+		file.Hunks = append(file.Hunks, interdiffHunk)
+	}
+
+	return file
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeInterdiffGetter struct {
+	testBase
+	rebased bool
+}
+
+func (f fakeInterdiffGetter) GetMergeRequestDiffVersions(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestDiffVersionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.MergeRequestDiffVersion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	latestBase := "base000"
+	if f.rebased {
+		latestBase = "base999"
+	}
+	return []*gitlab.MergeRequestDiffVersion{
+		{ID: 3, HeadCommitSHA: "head333", BaseCommitSHA: latestBase},
+		{ID: 2, HeadCommitSHA: "head222", BaseCommitSHA: "base000"},
+		{ID: 1, HeadCommitSHA: "head111", BaseCommitSHA: "base000"},
+	}, resp, err
+}
+
+func (f fakeInterdiffGetter) GetMergeRequestDiffVersionFiles(pid interface{}, mergeRequest int, version int, options ...gitlab.RequestOptionFunc) (*DiffVersionFiles, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &DiffVersionFiles{Diffs: []*DiffFile{{OldPath: "main.go", NewPath: "main.go"}}}, resp, err
+}
+
+func (f fakeInterdiffGetter) CompareDiffFiles(pid interface{}, from string, to string, options ...gitlab.RequestOptionFunc) ([]*DiffFile, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*DiffFile{
+		{OldPath: "main.go", NewPath: "main.go", Diff: "@@ -1,2 +1,2 @@\n package main\n-var a = 1\n+var a = 2\n"},
+		{OldPath: "upstream.go", NewPath: "upstream.go", Diff: "@@ -0,0 +1 @@\n+package main\n", NewFile: true},
+	}, resp, err
+}
+
+func TestInterdiffHandler(t *testing.T) {
+	interdiffService := func(client InterdiffGetter) http.HandlerFunc {
+		return middleware(
+			interdiffService{testProjectData, client},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[InterdiffRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+	}
+
+	t.Run("Returns the hunks changed since a version, up to the latest one", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/interdiff", InterdiffRequest{FromVersionID: 1})
+		data := decodeResponse[InterdiffResponse](t, interdiffService(fakeInterdiffGetter{}), request)
+		assert(t, data.Message, "Interdiff retrieved")
+		assert(t, data.FromVersion.HeadCommitSHA, "head111")
+		assert(t, data.ToVersion.HeadCommitSHA, "head333")
+		assert(t, data.Rebased, false)
+		assert(t, len(data.Files), 2)
+
+		file := data.Files[0]
+		assert(t, file.Additions, 1)
+		assert(t, file.Deletions, 1)
+		assert(t, file.Hunks[0].Header, "@@ -1,2 +1,2 @@")
+		assert(t, file.Hunks[0].Lines[1], InterdiffLine{Type: "removed", OldLine: 2, Text: "var a = 1"})
+		assert(t, file.Hunks[0].Lines[2], InterdiffLine{Type: "added", NewLine: 2, Text: "var a = 2"})
+	})
+	t.Run("Compares two chosen versions", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/interdiff", InterdiffRequest{FromVersionID: 1, ToVersionID: 2})
+		data := decodeResponse[InterdiffResponse](t, interdiffService(fakeInterdiffGetter{}), request)
+		assert(t, data.ToVersion.HeadCommitSHA, "head222")
+	})
+	t.Run("Leaves out files outside of the merge request after a rebase", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/interdiff", InterdiffRequest{FromVersionID: 2})
+		data := decodeResponse[InterdiffResponse](t, interdiffService(fakeInterdiffGetter{rebased: true}), request)
+		assert(t, data.Rebased, true)
+		assert(t, data.SkippedFiles, 1)
+		assert(t, len(data.Files), 1)
+		assert(t, data.Files[0].NewPath, "main.go")
+	})
+	t.Run("Rejects unknown versions", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/interdiff", InterdiffRequest{FromVersionID: 1, ToVersionID: 9})
+		data, status := getFailData(t, interdiffService(fakeInterdiffGetter{}), request)
+		assert(t, status, http.StatusNotFound)
+		assert(t, data.Message, "Could not find diff version")
+	})
+	t.Run("Requires the version to compare from", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/interdiff", InterdiffRequest{})
+		_, status := getFailData(t, interdiffService(fakeInterdiffGetter{}), request)
+		assert(t, status, http.StatusBadRequest)
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/interdiff", InterdiffRequest{FromVersionID: 1})
+		data, _ := getFailData(t, interdiffService(fakeInterdiffGetter{testBase{errFromGitlab: true}, false}), request)
+		checkErrorFromGitlab(t, data, "Could not get diff version info")
+	})
+	t.Run("Handles non-200s from Gitlab", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/interdiff", InterdiffRequest{FromVersionID: 1})
+		data, _ := getFailData(t, interdiffService(fakeInterdiffGetter{testBase{status: http.StatusSeeOther}, false}), request)
+		checkNon200(t, data, "Could not get diff version info", "/mr/interdiff")
+	})
+}
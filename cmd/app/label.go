@@ -102,9 +102,9 @@ func (a labelService) updateLabels(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var labels = gitlab.LabelOptions(labelUpdateRequest.Labels)
-	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, &gitlab.UpdateMergeRequestOptions{
+	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.UpdateMergeRequestOptions{
 		Labels: &labels,
-	})
+	}, withRetries())
 
 	if err != nil {
 		handleError(w, err, "Could not modify merge request labels", http.StatusInternalServerError)
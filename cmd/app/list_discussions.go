@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"net/http"
 	"sort"
 	"sync"
@@ -25,18 +26,46 @@ type SortBy string
 const (
 	SortByLatestReply     SortBy = "latest_reply"
 	SortByOriginalComment SortBy = "original_comment"
+	SortByFileAndLine     SortBy = "file_line"
+)
+
+const (
+	discussionsPerPage         = 100
+	discussionsPageConcurrency = 4
+	emojiFetchConcurrency      = 8
 )
 
 type DiscussionsRequest struct {
-	Blacklist []string `json:"blacklist" validate:"required"`
-	SortBy    SortBy   `json:"sort_by"`
+	Blacklist []string         `json:"blacklist" validate:"required"`
+	SortBy    SortBy           `json:"sort_by"`
+	Filter    DiscussionFilter `json:"filter"`
 }
 
 type DiscussionsResponse struct {
 	SuccessResponse
-	Discussions         []*gitlab.Discussion         `json:"discussions"`
-	UnlinkedDiscussions []*gitlab.Discussion         `json:"unlinked_discussions"`
-	Emojis              map[int][]*gitlab.AwardEmoji `json:"emojis"`
+	Total               int                           `json:"total"`
+	Matched             int                           `json:"matched"`
+	Discussions         []*gitlab.Discussion          `json:"discussions"`
+	UnlinkedDiscussions []*gitlab.Discussion          `json:"unlinked_discussions"`
+	Emojis              map[int][]*gitlab.AwardEmoji  `json:"emojis"`
+	EmojiErrors         map[int]string                `json:"emoji_errors,omitempty"`
+	Positions           map[string]DiscussionPosition `json:"positions,omitempty"`
+	PositionsError      string                        `json:"positions_error,omitempty"`
+}
+
+/*
+DiscussionPosition says where a linked discussion sits on the latest version of the diff. Current discussions
+were left on the latest version. Moved discussions were left on an older version and their lines are now
+elsewhere, given by FileName, NewLine and OldLine. Outdated discussions point at lines that no longer exist.
+*/
+type DiscussionPosition struct {
+	Current  bool   `json:"current"`
+	Moved    bool   `json:"moved"`
+	Outdated bool   `json:"outdated"`
+	FileName string `json:"file_name,omitempty"`
+	NewLine  *int   `json:"new_line,omitempty"`
+	OldLine  *int   `json:"old_line,omitempty"`
+	Reason   string `json:"reason,omitempty"`
 }
 
 type SortableDiscussions struct {
@@ -50,7 +79,17 @@ func (d SortableDiscussions) Len() int {
 
 func (d SortableDiscussions) Less(i, j int) bool {
 	var iTime, jTime *time.Time
-	if d.SortBy == SortByOriginalComment {
+	if d.SortBy == SortByFileAndLine {
+		iFile, iLine := discussionLocation(d.Discussions[i])
+		jFile, jLine := discussionLocation(d.Discussions[j])
+		if iFile != jFile {
+			return iFile < jFile
+		}
+		if iLine != jLine {
+			return iLine < jLine
+		}
+		return d.Discussions[i].Notes[0].CreatedAt.Before(*d.Discussions[j].Notes[0].CreatedAt)
+	} else if d.SortBy == SortByOriginalComment {
 		iTime = d.Discussions[i].Notes[0].CreatedAt
 		jTime = d.Discussions[j].Notes[0].CreatedAt
 		return iTime.Before(*jTime)
@@ -65,9 +104,29 @@ func (d SortableDiscussions) Swap(i, j int) {
 	d.Discussions[i], d.Discussions[j] = d.Discussions[j], d.Discussions[i]
 }
 
+/* discussionLocation is the file and line a discussion was left on, used to group discussions by file */
+func discussionLocation(discussion *gitlab.Discussion) (string, int) {
+	position := discussion.Notes[0].Position
+	if position == nil {
+		return "", 0
+	}
+	file := position.NewPath
+	if file == "" {
+		file = position.OldPath
+	}
+	line := position.NewLine
+	if line == 0 {
+		line = position.OldLine
+	}
+	return file, line
+}
+
 type DiscussionsLister interface {
 	ListMergeRequestDiscussions(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiscussionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Discussion, *gitlab.Response, error)
 	ListMergeRequestAwardEmojiOnNote(pid interface{}, mergeRequestIID int, noteID int, opt *gitlab.ListAwardEmojiOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.AwardEmoji, *gitlab.Response, error)
+	RevisionsGetter
+	DiffComparer
+	MeGetter
 }
 
 type discussionsListerService struct {
@@ -83,12 +142,7 @@ func (a discussionsListerService) ServeHTTP(w http.ResponseWriter, r *http.Reque
 
 	request := r.Context().Value(payload(payload("payload"))).(*DiscussionsRequest)
 
-	mergeRequestDiscussionOptions := gitlab.ListMergeRequestDiscussionsOptions{
-		Page:    1,
-		PerPage: 250,
-	}
-
-	discussions, res, err := a.client.ListMergeRequestDiscussions(a.projectInfo.ProjectId, a.projectInfo.MergeId, &mergeRequestDiscussionOptions)
+	discussions, res, err := a.fetchAllDiscussions(a.mergeId(r))
 
 	if err != nil {
 		handleError(w, err, "Could not list discussions", http.StatusInternalServerError)
@@ -100,8 +154,30 @@ func (a discussionsListerService) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	/* Filter out any discussions started by a blacklisted user
-	and system discussions, then return them sorted by created date */
+	normalizeInternalNotes(discussions)
+
+	username := ""
+	if request.Filter.MentionsMe {
+		user, res, err := a.client.CurrentUser()
+		if err != nil {
+			handleError(w, err, "Could not get current user", http.StatusInternalServerError)
+			return
+		}
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, "Could not get current user", res.StatusCode)
+			return
+		}
+		username = user.Username
+	}
+
+	matcher, err := newDiscussionMatcher(request.Filter, username)
+	if err != nil {
+		handleError(w, err, "Could not filter discussions", http.StatusBadRequest)
+		return
+	}
+
+	/* Filter out any discussions started by a blacklisted user, system discussions,
+	and discussions not matching the filter, then return them sorted */
 	var unlinkedDiscussions []*gitlab.Discussion
 	var linkedDiscussions []*gitlab.Discussion
 
@@ -109,6 +185,9 @@ func (a discussionsListerService) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		if len(discussion.Notes) == 0 || Contains(request.Blacklist, discussion.Notes[0].Author.Username) {
 			continue
 		}
+		if !matcher.matches(discussion) {
+			continue
+		}
 		for _, note := range discussion.Notes {
 			if note.Type == gitlab.NoteTypeValue("DiffNote") {
 				linkedDiscussions = append(linkedDiscussions, discussion)
@@ -120,18 +199,19 @@ func (a discussionsListerService) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	/* Collect IDs in order to fetch emojis */
-	var noteIds []int
-	for _, discussion := range discussions {
-		for _, note := range discussion.Notes {
-			noteIds = append(noteIds, note.ID)
-		}
+	/* Collect the notes we are returning in order to fetch their emojis */
+	var notes []*gitlab.Note
+	for _, discussion := range append(linkedDiscussions, unlinkedDiscussions...) {
+		notes = append(notes, discussion.Notes...)
 	}
 
-	emojis, err := a.fetchEmojisForNotesAndComments(noteIds)
+	emojis, emojiErrors := a.fetchEmojisForNotes(a.mergeId(r), notes)
+
+	/* Outdated positions are reported rather than failing the request, the discussions are still useful */
+	positions, err := a.discussionPositions(a.mergeId(r), linkedDiscussions)
+	positionsError := ""
 	if err != nil {
-		handleError(w, err, "Could not fetch emojis", http.StatusInternalServerError)
-		return
+		positionsError = err.Error()
 	}
 
 	sortedLinkedDiscussions := SortableDiscussions{
@@ -149,9 +229,14 @@ func (a discussionsListerService) ServeHTTP(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusOK)
 	response := DiscussionsResponse{
 		SuccessResponse:     SuccessResponse{Message: "Discussions retrieved"},
+		Total:               len(discussions),
+		Matched:             len(linkedDiscussions) + len(unlinkedDiscussions),
 		Discussions:         linkedDiscussions,
 		UnlinkedDiscussions: unlinkedDiscussions,
 		Emojis:              emojis,
+		EmojiErrors:         emojiErrors,
+		Positions:           positions,
+		PositionsError:      positionsError,
 	}
 
 	err = json.NewEncoder(w).Encode(response)
@@ -160,59 +245,189 @@ func (a discussionsListerService) ServeHTTP(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+/* discussionPositions follows each linked discussion from the version it was left on to the latest version */
+func (a discussionsListerService) discussionPositions(mergeId int, discussions []*gitlab.Discussion) (map[string]DiscussionPosition, error) {
+	if len(discussions) == 0 {
+		return nil, nil
+	}
+
+	versions, res, err := a.client.GetMergeRequestDiffVersions(a.projectInfo.ProjectId, mergeId, &gitlab.GetMergeRequestDiffVersionsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("getting diff versions returned status %d", res.StatusCode)
+	}
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	translator := newPositionTranslator(a.client, a.projectInfo.ProjectId, versions[0], a.compareCache)
+	positions := make(map[string]DiscussionPosition, len(discussions))
+	for _, discussion := range discussions {
+		if len(discussion.Notes) == 0 || discussion.Notes[0].Position == nil {
+			continue
+		}
+
+		original := positionDataFromNote(discussion.Notes[0].Position)
+		translated, err := translator.translate(original)
+		if err != nil {
+			return nil, err
+		}
+
+		position := DiscussionPosition{
+			Current:  !translated.Changed,
+			Outdated: translated.Outdated,
+			Reason:   translated.Reason,
+		}
+		if translated.Changed && !translated.Outdated {
+			moved := translated.Position
+			position.Moved = moved.FileName != original.FileName || !sameLine(moved.NewLine, original.NewLine) || !sameLine(moved.OldLine, original.OldLine)
+			position.FileName = moved.FileName
+			position.NewLine = moved.NewLine
+			position.OldLine = moved.OldLine
+		}
+		positions[discussion.ID] = position
+	}
+
+	return positions, nil
+}
+
+func sameLine(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 /*
-Fetches emojis for a set of notes and comments in parallel and returns a map of note IDs to their emojis.
-Gitlab's API does not allow for fetching notes for an entire discussion thread so we have to do it per-note.
+fetchAllDiscussions walks every page of discussions on the merge request. Once the first page tells us how many
+pages there are, the rest are fetched concurrently. Gitlab omits the page count for very large collections, in which
+case we follow the NextPage header one page at a time. A non-nil response with a non-2xx status is returned as-is.
 */
-func (a discussionsListerService) fetchEmojisForNotesAndComments(noteIDs []int) (map[int][]*gitlab.AwardEmoji, error) {
+func (a discussionsListerService) fetchAllDiscussions(mergeId int) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	opt := gitlab.ListMergeRequestDiscussionsOptions{
+		Page:    1,
+		PerPage: discussionsPerPage,
+	}
+
+	discussions, res, err := a.client.ListMergeRequestDiscussions(a.projectInfo.ProjectId, mergeId, &opt)
+	if err != nil || res.StatusCode >= 300 {
+		return nil, res, err
+	}
+
+	if res.TotalPages > 1 {
+		return a.fetchRemainingPagesConcurrently(mergeId, discussions, res)
+	}
+
+	for res.NextPage != 0 {
+		opt.Page = res.NextPage
+		var page []*gitlab.Discussion
+		page, res, err = a.client.ListMergeRequestDiscussions(a.projectInfo.ProjectId, mergeId, &opt)
+		if err != nil || res.StatusCode >= 300 {
+			return nil, res, err
+		}
+		discussions = append(discussions, page...)
+	}
+
+	return discussions, res, nil
+}
+
+/* fetchRemainingPagesConcurrently fetches pages 2 through TotalPages with a bounded number of requests in flight, preserving page order */
+func (a discussionsListerService) fetchRemainingPagesConcurrently(mergeId int, firstPage []*gitlab.Discussion, firstRes *gitlab.Response) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	type pageResult struct {
+		discussions []*gitlab.Discussion
+		res         *gitlab.Response
+		err         error
+	}
+
+	results := make([]pageResult, firstRes.TotalPages+1)
+	sem := make(chan struct{}, discussionsPageConcurrency)
 	var wg sync.WaitGroup
 
+	for page := 2; page <= firstRes.TotalPages; page++ {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			opt := gitlab.ListMergeRequestDiscussionsOptions{
+				Page:    page,
+				PerPage: discussionsPerPage,
+			}
+			discussions, res, err := a.client.ListMergeRequestDiscussions(a.projectInfo.ProjectId, mergeId, &opt)
+			results[page] = pageResult{discussions, res, err}
+		}(page)
+	}
+
+	wg.Wait()
+
+	discussions := firstPage
+	for _, result := range results[2:] {
+		if result.err != nil || result.res.StatusCode >= 300 {
+			return nil, result.res, result.err
+		}
+		discussions = append(discussions, result.discussions...)
+	}
+
+	return discussions, firstRes, nil
+}
+
+/*
+fetchEmojisForNotes fetches the emojis for a set of notes using a bounded pool of workers and returns a map of note IDs
+to their emojis. Gitlab's API does not allow for fetching emojis for an entire discussion thread so we have to do it
+per-note. Notes that have not changed since their emojis were last fetched are served from the cache. A failure for
+one note is reported in the returned error map rather than failing the whole request.
+*/
+func (a discussionsListerService) fetchEmojisForNotes(mergeId int, notes []*gitlab.Note) (map[int][]*gitlab.AwardEmoji, map[int]string) {
 	emojis := make(map[int][]*gitlab.AwardEmoji)
+	emojiErrors := make(map[int]string)
+
+	var uncached []*gitlab.Note
+	for _, note := range notes {
+		if cached, ok := a.emojiCache.get(note); ok {
+			emojis[note.ID] = cached
+			continue
+		}
+		uncached = append(uncached, note)
+	}
+
+	workers := emojiFetchConcurrency
+	if len(uncached) < workers {
+		workers = len(uncached)
+	}
+
+	var wg sync.WaitGroup
 	mu := &sync.Mutex{}
-	errs := make(chan error, len(noteIDs))
-	emojiChan := make(chan struct {
-		noteID int
-		emojis []*gitlab.AwardEmoji
-	}, len(noteIDs))
+	queue := make(chan *gitlab.Note)
 
-	for _, noteID := range noteIDs {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(noteID int) {
+		go func() {
 			defer wg.Done()
-			emojis, _, err := a.client.ListMergeRequestAwardEmojiOnNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, noteID, &gitlab.ListAwardEmojiOptions{})
-			if err != nil {
-				errs <- err
-				return
-			}
-			emojiChan <- struct {
-				noteID int
-				emojis []*gitlab.AwardEmoji
-			}{noteID, emojis}
-		}(noteID)
-	}
-
-	/* Close the channels when all goroutines finish */
-	go func() {
-		wg.Wait()
-		close(errs)
-		close(emojiChan)
-	}()
-
-	/* Collect emojis */
-	for e := range emojiChan {
-		mu.Lock()
-		emojis[e.noteID] = e.emojis
-		mu.Unlock()
-	}
-
-	/* Check if any errors occurred */
-	if len(errs) > 0 {
-		for err := range errs {
-			if err != nil {
-				return nil, err
+			for note := range queue {
+				noteEmojis, res, err := a.client.ListMergeRequestAwardEmojiOnNote(a.projectInfo.ProjectId, mergeId, note.ID, &gitlab.ListAwardEmojiOptions{})
+				if err == nil && res.StatusCode >= 300 {
+					err = fmt.Errorf("listing emojis for note %d returned status %d", note.ID, res.StatusCode)
+				}
+
+				mu.Lock()
+				if err != nil {
+					emojiErrors[note.ID] = err.Error()
+				} else {
+					emojis[note.ID] = noteEmojis
+					a.emojiCache.set(note, noteEmojis)
+				}
+				mu.Unlock()
 			}
-		}
+		}()
+	}
+
+	for _, note := range uncached {
+		queue <- note
 	}
+	close(queue)
+	wg.Wait()
 
-	return emojis, nil
+	return emojis, emojiErrors
 }
@@ -3,8 +3,10 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +16,26 @@ import (
 type fakeDiscussionsLister struct {
 	testBase
 	badEmojiResponse bool
+	pages            int
+	hideTotalPages   bool
+	emojiCalls       *int32
+	withPositions    bool
+	compareCalls     *int32
+}
+
+/* listPage returns a single discussion per page, with the pagination headers Gitlab would set */
+func (f fakeDiscussionsLister) listPage(page int, resp *gitlab.Response) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	now := time.Date(2024, 1, 1, 0, 0, page, 0, time.UTC)
+	resp.CurrentPage = page
+	if page < f.pages {
+		resp.NextPage = page + 1
+	}
+	if !f.hideTotalPages {
+		resp.TotalPages = f.pages
+	}
+	return []*gitlab.Discussion{
+		{ID: fmt.Sprintf("page-%d", page), Notes: []*gitlab.Note{{ID: page, CreatedAt: &now, UpdatedAt: &now, Type: "DiffNote"}}},
+	}, resp, nil
 }
 
 func (f fakeDiscussionsLister) ListMergeRequestDiscussions(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiscussionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Discussion, *gitlab.Response, error) {
@@ -22,6 +44,23 @@ func (f fakeDiscussionsLister) ListMergeRequestDiscussions(pid interface{}, merg
 		return nil, nil, err
 	}
 
+	if f.pages > 0 {
+		return f.listPage(opt.Page, resp)
+	}
+
+	if f.withPositions {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		positioned := func(id string, head string, line int) *gitlab.Discussion {
+			position := &gitlab.NotePosition{NewPath: "main.go", OldPath: "main.go", HeadSHA: head, BaseSHA: "base", PositionType: "text", NewLine: line}
+			return &gitlab.Discussion{ID: id, Notes: []*gitlab.Note{{CreatedAt: &now, Type: "DiffNote", Position: position}}}
+		}
+		return []*gitlab.Discussion{
+			positioned("current", "head2", 3),
+			positioned("moved", "head1", 2),
+			positioned("outdated", "head1", 3),
+		}, resp, err
+	}
+
 	timePointers := make([]*time.Time, 6)
 	timePointers[0] = new(time.Time)
 	*timePointers[0] = time.Now()
@@ -43,10 +82,10 @@ func (f fakeDiscussionsLister) ListMergeRequestDiscussions(pid interface{}, merg
 	testListDiscussionsResponse := []*gitlab.Discussion{
 		{Notes: []*gitlab.Note{
 			{CreatedAt: timePointers[0], Type: "DiffNote", Author: Author{Username: "hcramer0"}},
-			{CreatedAt: timePointers[4], Type: "DiffNote", Author: Author{Username: "hcramer1"}},
+			{CreatedAt: timePointers[4], Type: "DiffNote", Author: Author{Username: "hcramer1"}, Confidential: true},
 		}},
 		{Notes: []*gitlab.Note{
-			{CreatedAt: timePointers[2], Type: "DiffNote", Author: Author{Username: "hcramer2"}},
+			{CreatedAt: timePointers[2], Type: "DiffNote", Author: Author{Username: "hcramer2"}, Body: "Thoughts @hcramer?"},
 			{CreatedAt: timePointers[3], Type: "DiffNote", Author: Author{Username: "hcramer3"}},
 		}},
 		{Notes: []*gitlab.Note{
@@ -63,12 +102,49 @@ func (f fakeDiscussionsLister) ListMergeRequestAwardEmojiOnNote(pid interface{},
 		return nil, nil, err
 	}
 
+	if f.emojiCalls != nil {
+		atomic.AddInt32(f.emojiCalls, 1)
+	}
+
 	if f.badEmojiResponse {
 		return nil, nil, errors.New("Some error from emoji service")
 	}
 	return []*gitlab.AwardEmoji{}, resp, err
 }
 
+func (f fakeDiscussionsLister) GetMergeRequestDiffVersions(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestDiffVersionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.MergeRequestDiffVersion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*gitlab.MergeRequestDiffVersion{{ID: 2, HeadCommitSHA: "head2", BaseCommitSHA: "base"}}, resp, err
+}
+
+func (f fakeDiscussionsLister) Compare(pid interface{}, opt *gitlab.CompareOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Compare, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if f.compareCalls != nil {
+		atomic.AddInt32(f.compareCalls, 1)
+	}
+
+	return &gitlab.Compare{Diffs: []*gitlab.Diff{
+		{OldPath: "main.go", NewPath: "main.go", Diff: "@@ -1,3 +1,4 @@\n a\n+new\n b\n-c\n+C\n"},
+	}}, resp, err
+}
+
+func (f fakeDiscussionsLister) CurrentUser(options ...gitlab.RequestOptionFunc) (*gitlab.User, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gitlab.User{Username: "hcramer"}, resp, err
+}
+
 func getDiscussionsList(t *testing.T, svc http.Handler, request *http.Request) DiscussionsResponse {
 	res := httptest.NewRecorder()
 	svc.ServeHTTP(res, request)
@@ -126,6 +202,32 @@ func TestListDiscussions(t *testing.T) {
 		assert(t, data.Discussions[0].Notes[0].Author.Username, "hcramer4")
 		assert(t, data.Discussions[1].Notes[0].Author.Username, "hcramer2")
 	})
+	t.Run("Fetches every page when the page count is known", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}, SortBy: "original_comment"})
+		svc := middleware(
+			discussionsListerService{testProjectData, fakeDiscussionsLister{pages: 5}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getDiscussionsList(t, svc, request)
+		assert(t, data.Total, 5)
+		assert(t, len(data.Discussions), 5)
+		assert(t, data.Discussions[0].ID, "page-1")
+		assert(t, data.Discussions[4].ID, "page-5")
+	})
+	t.Run("Follows the next page when the page count is not known", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}, SortBy: "original_comment"})
+		svc := middleware(
+			discussionsListerService{testProjectData, fakeDiscussionsLister{pages: 3, hideTotalPages: true}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getDiscussionsList(t, svc, request)
+		assert(t, data.Total, 3)
+		assert(t, len(data.Discussions), 3)
+	})
 	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
 		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}})
 		svc := middleware(
@@ -148,16 +250,113 @@ func TestListDiscussions(t *testing.T) {
 		data, _ := getFailData(t, svc, request)
 		checkNon200(t, data, "Could not list discussions", "/mr/discussions/list")
 	})
-	t.Run("Handles error from emoji service", func(t *testing.T) {
+	t.Run("Reports errors from emoji service without failing the request", func(t *testing.T) {
 		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}})
 		svc := middleware(
-			discussionsListerService{testProjectData, fakeDiscussionsLister{badEmojiResponse: true, testBase: testBase{}}},
+			discussionsListerService{testProjectData, fakeDiscussionsLister{badEmojiResponse: true, pages: 2}},
 			withMr(testProjectData, fakeMergeRequestLister{}),
 			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
 			withMethodCheck(http.MethodPost),
 		)
-		data, _ := getFailData(t, svc, request)
-		assert(t, data.Message, "Could not fetch emojis")
-		assert(t, data.Details, "Some error from emoji service")
+		data := getDiscussionsList(t, svc, request)
+		assert(t, data.Message, "Discussions retrieved")
+		assert(t, len(data.Discussions), 2)
+		assert(t, len(data.EmojiErrors), 2)
+		assert(t, data.EmojiErrors[1], "Some error from emoji service")
+	})
+	t.Run("Serves emojis for unchanged notes from the cache", func(t *testing.T) {
+		var calls int32
+		d := testProjectData
+		d.emojiCache = newNoteEmojiCache()
+		svc := middleware(
+			discussionsListerService{d, fakeDiscussionsLister{pages: 3, emojiCalls: &calls}},
+			withMr(d, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		for i := 0; i < 2; i++ {
+			request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}})
+			data := getDiscussionsList(t, svc, request)
+			assert(t, len(data.Emojis), 3)
+		}
+		assert(t, atomic.LoadInt32(&calls), int32(3))
+	})
+	t.Run("Reports where discussions from older versions are now", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}})
+		svc := middleware(
+			discussionsListerService{testProjectData, fakeDiscussionsLister{withPositions: true}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getDiscussionsList(t, svc, request)
+		assert(t, data.PositionsError, "")
+		assert(t, len(data.Positions), 3)
+		assert(t, data.Positions["current"].Current, true)
+		assert(t, data.Positions["current"].Moved, false)
+		assert(t, data.Positions["moved"].Moved, true)
+		assert(t, *data.Positions["moved"].NewLine, 3)
+		assert(t, data.Positions["outdated"].Outdated, true)
+		assert(t, data.Positions["outdated"].Reason, "line 3 of main.go no longer exists")
+	})
+	t.Run("Compares each pair of commits only once across requests", func(t *testing.T) {
+		var calls int32
+		d := testProjectData
+		d.compareCache = newCompareCache()
+		svc := middleware(
+			discussionsListerService{d, fakeDiscussionsLister{withPositions: true, compareCalls: &calls}},
+			withMr(d, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}})
+		getDiscussionsList(t, svc, request)
+		first := atomic.LoadInt32(&calls)
+		assert(t, first > 0, true)
+
+		request = makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}})
+		data := getDiscussionsList(t, svc, request)
+		assert(t, data.Positions["moved"].Moved, true)
+		assert(t, atomic.LoadInt32(&calls), first)
+	})
+	t.Run("Filters discussions by author and mentions", func(t *testing.T) {
+		svc := middleware(
+			discussionsListerService{testProjectData, fakeDiscussionsLister{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}, Filter: DiscussionFilter{Authors: []string{"hcramer0", "hcramer4"}}})
+		data := getDiscussionsList(t, svc, request)
+		assert(t, data.Total, 3)
+		assert(t, data.Matched, 2)
+
+		request = makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}, Filter: DiscussionFilter{MentionsMe: true}})
+		data = getDiscussionsList(t, svc, request)
+		assert(t, data.Matched, 1)
+		assert(t, data.Discussions[0].Notes[0].Author.Username, "hcramer2")
+	})
+	t.Run("Flags confidential notes as internal", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}, Filter: DiscussionFilter{Authors: []string{"hcramer0"}}})
+		svc := middleware(
+			discussionsListerService{testProjectData, fakeDiscussionsLister{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getDiscussionsList(t, svc, request)
+		assert(t, data.Discussions[0].Notes[0].Internal, false)
+		assert(t, data.Discussions[0].Notes[1].Internal, true)
+	})
+	t.Run("Rejects unknown discussion states", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/discussions/list", DiscussionsRequest{Blacklist: []string{}, Filter: DiscussionFilter{State: "open"}})
+		svc := middleware(
+			discussionsListerService{testProjectData, fakeDiscussionsLister{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiscussionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		_, status := getFailData(t, svc, request)
+		assert(t, status, http.StatusBadRequest)
 	})
 }
@@ -36,6 +36,11 @@ func (l LoggingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if pluginOptions.Debug.Request {
 		logRequest("REQUEST TO GO SERVER", r)
 	}
+	// Event streams never finish, so their responses are written directly rather than buffered for logging
+	if r.URL.Path == "/events" {
+		l.handler.ServeHTTP(w, r)
+		return
+	}
 	lrw := &LoggingResponseWriter{ResponseWriter: w, body: &bytes.Buffer{}}
 	l.handler.ServeHTTP(lrw, r)
 	resp := &http.Response{
@@ -55,13 +60,20 @@ func logRequest(prefix string, r *http.Request) {
 	file := openLogFile()
 	defer file.Close()
 	token := r.Header.Get("Private-Token")
+	secret := r.Header.Get(secretHeader)
 	r.Header.Set("Private-Token", "REDACTED")
+	if secret != "" {
+		r.Header.Set(secretHeader, "REDACTED")
+	}
 	res, err := httputil.DumpRequest(r, true)
 	if err != nil {
 		log.Fatalf("Error dumping request: %v", err)
 		os.Exit(1)
 	}
 	r.Header.Set("Private-Token", token)
+	if secret != "" {
+		r.Header.Set(secretHeader, secret)
+	}
 	fmt.Fprintf(file, "\n-- %s --\n%s\n", prefix, res) //nolint:errcheck
 }
 
@@ -78,6 +90,13 @@ func logResponse(prefix string, r *http.Response) {
 	fmt.Fprintf(file, "\n-- %s --\n%s\n", prefix, res) //nolint:errcheck
 }
 
+func logMessage(prefix string, message string) {
+	file := openLogFile()
+	defer file.Close()
+
+	fmt.Fprintf(file, "\n-- %s --\n%s\n", prefix, message) //nolint:errcheck
+}
+
 func openLogFile() *os.File {
 	file, err := os.OpenFile(pluginOptions.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -3,18 +3,34 @@ package app
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/xanzy/go-gitlab"
 )
 
+/* AcceptMergeRequestRequest merges the MR, or with DryRun set only plans the merge and returns what it would do */
 type AcceptMergeRequestRequest struct {
 	DeleteBranch  bool   `json:"delete_branch"`
 	SquashMessage string `json:"squash_message"`
 	Squash        bool   `json:"squash"`
+	DryRun        bool   `json:"dry_run"`
+}
+
+/*
+MergePlanResponse describes a merge without performing it. When squashing, SquashMessage is the message that
+was asked for, or otherwise one built from the messages of the commits that would be squashed.
+*/
+type MergePlanResponse struct {
+	SuccessResponse
+	Squash        bool             `json:"squash"`
+	DeleteBranch  bool             `json:"delete_branch"`
+	Commits       []*gitlab.Commit `json:"commits"`
+	SquashMessage string           `json:"squash_message"`
 }
 
 type MergeRequestAccepter interface {
 	AcceptMergeRequest(pid interface{}, mergeRequest int, opt *gitlab.AcceptMergeRequestOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error)
+	MergeRequestCommitsLister
 }
 
 type mergeRequestAccepterService struct {
@@ -26,6 +42,11 @@ type mergeRequestAccepterService struct {
 func (a mergeRequestAccepterService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	payload := r.Context().Value(payload("payload")).(*AcceptMergeRequestRequest)
 
+	if payload.DryRun {
+		a.planMerge(w, r, payload)
+		return
+	}
+
 	opts := gitlab.AcceptMergeRequestOptions{
 		Squash:                   &payload.Squash,
 		ShouldRemoveSourceBranch: &payload.DeleteBranch,
@@ -35,7 +56,7 @@ func (a mergeRequestAccepterService) ServeHTTP(w http.ResponseWriter, r *http.Re
 		opts.SquashCommitMessage = &payload.SquashMessage
 	}
 
-	_, res, err := a.client.AcceptMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, &opts)
+	_, res, err := a.client.AcceptMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), &opts)
 
 	if err != nil {
 		handleError(w, err, "Could not merge MR", http.StatusInternalServerError)
@@ -56,3 +77,55 @@ func (a mergeRequestAccepterService) ServeHTTP(w http.ResponseWriter, r *http.Re
 		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
 	}
 }
+
+/* planMerge lists the commits the merge would bring into the target branch, without merging */
+func (a mergeRequestAccepterService) planMerge(w http.ResponseWriter, r *http.Request, payload *AcceptMergeRequestRequest) {
+	commits, res, err := a.client.ListAllMergeRequestCommits(a.projectInfo.ProjectId, a.mergeId(r))
+	if err != nil {
+		handleError(w, err, "Could not plan merge", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not plan merge", res.StatusCode)
+		return
+	}
+
+	response := MergePlanResponse{
+		SuccessResponse: SuccessResponse{Message: "Merge planned"},
+		Squash:          payload.Squash,
+		DeleteBranch:    payload.DeleteBranch,
+		Commits:         commits,
+	}
+
+	if payload.Squash {
+		response.SquashMessage = payload.SquashMessage
+		if response.SquashMessage == "" {
+			response.SquashMessage = defaultSquashMessage(commits)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/*
+defaultSquashMessage joins the messages of the commits oldest first, the way an interactive rebase would. The
+commits are expected newest first, as Gitlab lists them.
+*/
+func defaultSquashMessage(commits []*gitlab.Commit) string {
+	messages := make([]string, 0, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		commit := commits[i]
+		message := strings.TrimSpace(commit.Message)
+		if message == "" {
+			message = commit.Title
+		}
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, "\n\n")
+}
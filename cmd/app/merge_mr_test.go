@@ -20,6 +20,20 @@ func (f fakeMergeRequestAccepter) AcceptMergeRequest(pid interface{}, mergeReque
 	return &gitlab.MergeRequest{}, resp, err
 }
 
+func (f fakeMergeRequestAccepter) ListAllMergeRequestCommits(pid interface{}, mergeRequest int, options ...gitlab.RequestOptionFunc) ([]*gitlab.Commit, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	/* Newest first, as Gitlab lists them */
+	return []*gitlab.Commit{
+		{ID: "ccc333", Title: "Add tests", Message: "Add tests"},
+		{ID: "bbb222", Title: "Fix typo", Message: "Fix typo"},
+		{ID: "aaa111", Title: "Add parser", Message: "Add parser\n\nHandles the new syntax.\n"},
+	}, resp, err
+}
+
 func TestAcceptAndMergeHandler(t *testing.T) {
 	var testAcceptMergeRequestPayload = AcceptMergeRequestRequest{Squash: false, SquashMessage: "Squash me!", DeleteBranch: false}
 	t.Run("Accepts and merges a merge request", func(t *testing.T) {
@@ -61,4 +75,34 @@ func TestAcceptAndMergeHandler(t *testing.T) {
 		data, _ := getFailData(t, svc, request)
 		checkNon200(t, data, "Could not merge MR", "/mr/merge")
 	})
+	t.Run("Plans a squash without merging, with the oldest commit's message first", func(t *testing.T) {
+		payload := AcceptMergeRequestRequest{Squash: true, DryRun: true}
+		request := makeRequest(t, http.MethodPost, "/mr/merge", payload)
+		svc := middleware(
+			mergeRequestAccepterService{testProjectData, fakeMergeRequestAccepter{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{
+				http.MethodPost: newPayload[AcceptMergeRequestRequest],
+			}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := decodeResponse[MergePlanResponse](t, svc, request)
+		assert(t, data.Message, "Merge planned")
+		assert(t, len(data.Commits), 3)
+		assert(t, data.SquashMessage, "Add parser\n\nHandles the new syntax.\n\nFix typo\n\nAdd tests")
+	})
+	t.Run("Keeps the requested squash message in the plan", func(t *testing.T) {
+		payload := AcceptMergeRequestRequest{Squash: true, SquashMessage: "Squash me!", DryRun: true}
+		request := makeRequest(t, http.MethodPost, "/mr/merge", payload)
+		svc := middleware(
+			mergeRequestAccepterService{testProjectData, fakeMergeRequestAccepter{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{
+				http.MethodPost: newPayload[AcceptMergeRequestRequest],
+			}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := decodeResponse[MergePlanResponse](t, svc, request)
+		assert(t, data.SquashMessage, "Squash me!")
+	})
 }
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"sync"
+)
+
+/*
+mergeRequestRegistry remembers the IIDs of the merge requests the server has been asked about and found, so
+that a single server can work with several MRs at once without looking each one up on every request. Only the
+IIDs are kept, handlers fetch the MR itself when they need it so that it is never stale. A nil registry
+remembers nothing.
+*/
+type mergeRequestRegistry struct {
+	mu   sync.RWMutex
+	iids map[int]struct{}
+}
+
+func newMergeRequestRegistry() *mergeRequestRegistry {
+	return &mergeRequestRegistry{iids: make(map[int]struct{})}
+}
+
+func (m *mergeRequestRegistry) has(iid int) bool {
+	if m == nil {
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.iids[iid]
+	return ok
+}
+
+func (m *mergeRequestRegistry) add(iid int) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.iids[iid] = struct{}{}
+}
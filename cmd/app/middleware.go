@@ -2,11 +2,14 @@ package app
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -26,7 +29,14 @@ func middleware(h http.Handler, middlewares ...mw) http.HandlerFunc {
 	return h.ServeHTTP
 }
 
-var validate = validator.New()
+var validate = newValidator()
+
+/* newValidator registers the checks that span several fields, which struct tags cannot express */
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterStructValidation(validatePositionData, PositionData{})
+	return v
+}
 
 type methodToPayload map[string]func() any
 
@@ -93,13 +103,55 @@ func withPayloadValidation(mtp methodToPayload) mw {
 }
 
 type withMrMiddleware struct {
-	data   data
-	client MergeRequestLister
+	data     data
+	client   MergeRequestLister
+	optional bool
 }
 
-// Gets the current merge request ID and attaches it to the projectInfo
+type mergeIdKey string
+
+// Gets the merge request ID for the request and makes it available to the handlers via mergeId. An MR can
+// be chosen per-request with the mr_iid query parameter (or an /mr/<iid>/ path prefix), otherwise we fall
+// back to the MR for the current branch, which is looked up once and attached to the projectInfo
 func (m withMrMiddleware) handle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if iid := r.URL.Query().Get("mr_iid"); iid != "" {
+			mergeId, err := strconv.Atoi(iid)
+			if err != nil || mergeId <= 0 {
+				handleError(w, InvalidRequestError{fmt.Sprintf("invalid merge request IID '%s'", iid)}, "Invalid merge request IID", http.StatusBadRequest)
+				return
+			}
+
+			if !m.data.mergeRequests.has(mergeId) {
+				options := gitlab.ListProjectMergeRequestsOptions{
+					IIDs: gitlab.Ptr([]int{mergeId}),
+				}
+
+				mergeRequests, _, err := m.client.ListProjectMergeRequests(m.data.projectInfo.ProjectId, &options)
+				if err != nil {
+					handleError(w, fmt.Errorf("failed to list merge requests: %w", err), "Failed to list merge requests", http.StatusInternalServerError)
+					return
+				}
+
+				if len(mergeRequests) == 0 {
+					err := fmt.Errorf("merge request !%d does not exist", mergeId)
+					handleError(w, err, "No MRs Found", http.StatusNotFound)
+					return
+				}
+
+				m.data.mergeRequests.add(mergeRequests[0].IID)
+			}
+
+			ctx := context.WithValue(r.Context(), mergeIdKey("mergeId"), mergeId)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if m.optional {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// If the merge request is already attached, skip the middleware logic
 		if m.data.projectInfo.MergeId == 0 {
 			options := gitlab.ListProjectMergeRequestsOptions{
@@ -131,6 +183,7 @@ func (m withMrMiddleware) handle(next http.Handler) http.Handler {
 
 			mergeIdInt := mergeRequests[0].IID
 			m.data.projectInfo.MergeId = mergeIdInt
+			m.data.mergeRequests.add(mergeRequests[0].IID)
 		}
 
 		// Call the next handler if middleware succeeds
@@ -138,9 +191,70 @@ func (m withMrMiddleware) handle(next http.Handler) http.Handler {
 	})
 }
 
-// Att
+// Attaches the merge request ID for the request, see withMrMiddleware
 func withMr(data data, client MergeRequestLister) mw {
-	return withMrMiddleware{data, client}.handle
+	return withMrMiddleware{data, client, false}.handle
+}
+
+// Attaches the merge request ID when the request chooses one, for routes that also work without an MR
+func withOptionalMr(data data, client MergeRequestLister) mw {
+	return withMrMiddleware{data, client, true}.handle
+}
+
+// Returns the merge request ID the request chose with mr_iid, if any
+func chosenMergeId(r *http.Request) (int, bool) {
+	mergeId, ok := r.Context().Value(mergeIdKey("mergeId")).(int)
+	return mergeId, ok
+}
+
+// Returns the merge request ID chosen for the request by withMr, or the MR for the current branch
+func (d data) mergeId(r *http.Request) int {
+	if mergeId, ok := chosenMergeId(r); ok {
+		return mergeId
+	}
+	return d.projectInfo.MergeId
+}
+
+var mrPathPattern = regexp.MustCompile(`^/mr/(\d+)(/.*)$`)
+
+// Rewrites /mr/<iid>/<route> paths to /mr/<route>?mr_iid=<iid> so that every MR route
+// can take the IID in its path without registering each route twice
+func withMrPathRewrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		matches := mrPathPattern.FindStringSubmatch(r.URL.Path)
+		if matches != nil {
+			query := r.URL.Query()
+			query.Set("mr_iid", matches[1])
+			r.URL.Path = "/mr" + matches[2]
+			r.URL.RawPath = ""
+			r.URL.RawQuery = query.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+const secretHeader = "X-Gitlab-Nvim-Secret"
+
+type secretMiddleware struct {
+	secret string
+}
+
+// Rejects requests that do not carry the secret generated for this session, so that other local processes
+// cannot use the server (and the user's Gitlab token behind it)
+func (m secretMiddleware) handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := r.Header.Get(secretHeader)
+		if m.secret == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(m.secret)) != 1 {
+			err := fmt.Errorf("missing or invalid %s header", secretHeader)
+			handleError(w, err, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func withSecretCheck(secret string) mw {
+	return secretMiddleware{secret: secret}.handle
 }
 
 type methodMiddleware struct {
@@ -176,6 +290,12 @@ func formatValidationErrors(errs validator.ValidationErrors) error {
 		switch e.Tag() {
 		case "required":
 			s.WriteString(fmt.Sprintf("%s is required", e.Field()))
+		case "required_for_position":
+			s.WriteString(fmt.Sprintf("%s is required for %s positions", e.Field(), e.Param()))
+		case "excluded_for_position":
+			s.WriteString(fmt.Sprintf("%s is not allowed for %s positions", e.Field(), e.Param()))
+		case "oneof":
+			s.WriteString(fmt.Sprintf("%s must be one of: %s", e.Field(), e.Param()))
 		default:
 			s.WriteString(fmt.Sprintf("The field '%s' failed on validation on the '%s' tag", e.Field(), e.Tag()))
 		}
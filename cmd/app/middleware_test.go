@@ -2,6 +2,7 @@ package app
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 
@@ -50,6 +51,17 @@ func TestMethodMiddleware(t *testing.T) {
 	})
 }
 
+type fakeMergeIdHandler struct {
+	data
+}
+
+func (f fakeMergeIdHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	data := SuccessResponse{Message: fmt.Sprint(f.mergeId(r))}
+	j, _ := json.Marshal(data)
+	w.Write(j) // nolint
+}
+
 func TestWithMrMiddleware(t *testing.T) {
 	t.Run("Loads an MR ID into the projectInfo", func(t *testing.T) {
 		request := makeRequest(t, http.MethodGet, "/foo", nil)
@@ -90,6 +102,52 @@ func TestWithMrMiddleware(t *testing.T) {
 		assert(t, data.Message, "Multiple MRs found")
 		assert(t, data.Details, "please call gitlab.choose_merge_request()")
 	})
+	t.Run("Uses the MR IID from the query instead of the branch MR", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo?mr_iid=42", nil)
+		d := data{
+			projectInfo:   &ProjectInfo{},
+			gitInfo:       &git.GitData{BranchName: "foo"},
+			mergeRequests: newMergeRequestRegistry(),
+		}
+		handler := middleware(fakeMergeIdHandler{d}, withMr(d, fakeMergeRequestLister{}))
+		data := getSuccessData(t, handler, request)
+		assert(t, data.Message, "42")
+		assert(t, d.projectInfo.MergeId, 0)
+	})
+	t.Run("Uses the MR IID from the path", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/42/info", nil)
+		d := data{
+			projectInfo: &ProjectInfo{},
+			gitInfo:     &git.GitData{BranchName: "foo"},
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/mr/info", middleware(fakeMergeIdHandler{d}, withMr(d, fakeMergeRequestLister{})))
+		data := getSuccessData(t, withMrPathRewrite(mux), request)
+		assert(t, data.Message, "42")
+	})
+	t.Run("Handles an invalid MR IID", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo?mr_iid=abc", nil)
+		d := data{projectInfo: &ProjectInfo{}, gitInfo: &git.GitData{BranchName: "foo"}}
+		handler := middleware(fakeHandler{}, withMr(d, fakeMergeRequestLister{}))
+		data, status := getFailData(t, handler, request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Message, "Invalid merge request IID")
+	})
+	t.Run("Handles an MR IID that does not exist", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo?mr_iid=42", nil)
+		d := data{projectInfo: &ProjectInfo{}, gitInfo: &git.GitData{BranchName: "foo"}}
+		handler := middleware(fakeHandler{}, withMr(d, fakeMergeRequestLister{emptyResponse: true}))
+		data, status := getFailData(t, handler, request)
+		assert(t, status, http.StatusNotFound)
+		assert(t, data.Details, "merge request !42 does not exist")
+	})
+	t.Run("Does not need a branch MR when the MR is optional", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo", nil)
+		d := data{projectInfo: &ProjectInfo{}, gitInfo: &git.GitData{BranchName: "foo"}}
+		handler := middleware(fakeMergeIdHandler{d}, withOptionalMr(d, fakeMergeRequestLister{emptyResponse: true}))
+		data := getSuccessData(t, handler, request)
+		assert(t, data.Message, "0")
+	})
 }
 
 func TestValidatorMiddleware(t *testing.T) {
@@ -112,3 +170,34 @@ func TestValidatorMiddleware(t *testing.T) {
 		assert(t, data.Message, "Some message")
 	})
 }
+
+func TestSecretMiddleware(t *testing.T) {
+	t.Run("Rejects a request without the secret", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo", nil)
+		handler := middleware(fakeHandler{}, withSecretCheck("abc123"))
+		data, status := getFailData(t, handler, request)
+		assert(t, status, http.StatusUnauthorized)
+		assert(t, data.Message, "Unauthorized")
+		assert(t, data.Details, "missing or invalid X-Gitlab-Nvim-Secret header")
+	})
+	t.Run("Rejects a request with the wrong secret", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo", nil)
+		request.Header.Set(secretHeader, "wrong")
+		handler := middleware(fakeHandler{}, withSecretCheck("abc123"))
+		_, status := getFailData(t, handler, request)
+		assert(t, status, http.StatusUnauthorized)
+	})
+	t.Run("Rejects every request when no secret is configured", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo", nil)
+		handler := middleware(fakeHandler{}, withSecretCheck(""))
+		_, status := getFailData(t, handler, request)
+		assert(t, status, http.StatusUnauthorized)
+	})
+	t.Run("Allows a request with the secret through", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/foo", nil)
+		request.Header.Set(secretHeader, "abc123")
+		handler := middleware(fakeHandler{}, withSecretCheck("abc123"))
+		data := getSuccessData(t, handler, request)
+		assert(t, data.Message, "Some message")
+	})
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauthRefreshTokenEnvVariable = "GITLAB_OAUTH_REFRESH_TOKEN"
+	oauthClientSecretEnvVariable = "GITLAB_OAUTH_CLIENT_SECRET"
+)
+
+/*
+oauthTransport sets the OAuth access token on every request to Gitlab and refreshes it using the refresh token,
+either ahead of time when the expiry is known or after Gitlab rejects the token with a 401. Gitlab revokes a
+refresh token once it has been used, so the new tokens are saved to the token file for the next server start.
+*/
+type oauthTransport struct {
+	base      http.RoundTripper
+	config    *oauth2.Config
+	ctx       context.Context
+	tokenFile string
+
+	/* The refresh token the server was started with, tried if the saved one has been revoked */
+	fallbackRefreshToken string
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+/*
+newOAuthTransport wraps the base transport, the refresh requests to Gitlab go through the base transport too. Tokens
+saved by an earlier run take precedence over the ones passed in, since those have most likely been rotated since.
+*/
+func newOAuthTransport(base http.RoundTripper, accessToken string, refreshToken string, tokenFile string) *oauthTransport {
+	token := &oauth2.Token{AccessToken: accessToken, RefreshToken: refreshToken}
+	if saved, err := loadOAuthToken(tokenFile); err == nil && saved.RefreshToken != "" {
+		if saved.Valid() {
+			token = saved
+		} else {
+			token.RefreshToken = saved.RefreshToken
+		}
+	}
+
+	fallbackRefreshToken := ""
+	if refreshToken != token.RefreshToken {
+		fallbackRefreshToken = refreshToken
+	}
+
+	return &oauthTransport{
+		base: base,
+		config: &oauth2.Config{
+			ClientID:     pluginOptions.OAuthClientId,
+			ClientSecret: os.Getenv(oauthClientSecretEnvVariable),
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  pluginOptions.GitlabUrl + "/oauth/authorize",
+				TokenURL: pluginOptions.GitlabUrl + "/oauth/token",
+			},
+		},
+		ctx:                  context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: base}),
+		tokenFile:            tokenFile,
+		fallbackRefreshToken: fallbackRefreshToken,
+		token:                token,
+	}
+}
+
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.currentToken()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(withBearerToken(req, token.AccessToken))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	/* The token most likely expired, refresh it for later requests and retry this one if its body can be replayed */
+	refreshed, err := t.refresh(token)
+	if err != nil || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+
+	retry := withBearerToken(req, refreshed.AccessToken)
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+	}
+
+	resp.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+/* currentToken returns the access token, refreshing it first if it is known to have expired */
+func (t *oauthTransport) currentToken() (*oauth2.Token, error) {
+	t.mu.Lock()
+	token := t.token
+	t.mu.Unlock()
+
+	if token.Valid() {
+		return token, nil
+	}
+
+	return t.refresh(token)
+}
+
+/* refresh exchanges the refresh token for a new access token, unless another request already did */
+func (t *oauthTransport) refresh(stale *oauth2.Token) (*oauth2.Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != stale {
+		return t.token, nil
+	}
+
+	if t.token.RefreshToken == "" {
+		return nil, fmt.Errorf("OAuth token expired and %s is not set", oauthRefreshTokenEnvVariable)
+	}
+
+	/* A token without an access token is never valid, forcing the token source to refresh */
+	token, err := t.config.TokenSource(t.ctx, &oauth2.Token{RefreshToken: t.token.RefreshToken}).Token()
+	if err != nil && t.fallbackRefreshToken != "" {
+		token, err = t.config.TokenSource(t.ctx, &oauth2.Token{RefreshToken: t.fallbackRefreshToken}).Token()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not refresh OAuth token: %w", err)
+	}
+
+	t.token = token
+	t.fallbackRefreshToken = ""
+
+	/* The request can go ahead with the new token even if it could not be saved */
+	if err := saveOAuthToken(t.tokenFile, token); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not save refreshed OAuth token: %s\n", err)
+	}
+
+	return token, nil
+}
+
+/* loadOAuthToken reads the tokens saved by saveOAuthToken */
+func loadOAuthToken(path string) (*oauth2.Token, error) {
+	if path == "" {
+		return nil, errors.New("no OAuth token file")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	token := new(oauth2.Token)
+	err = json.Unmarshal(content, token)
+	if err != nil {
+		return nil, fmt.Errorf("could not read OAuth token file: %w", err)
+	}
+	return token, nil
+}
+
+/* saveOAuthToken writes the tokens readable only by the current user, replacing the file in one step */
+func saveOAuthToken(path string, token *oauth2.Token) error {
+	if path == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".oauth-token-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func withBearerToken(req *http.Request, accessToken string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	return r
+}
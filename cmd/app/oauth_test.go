@@ -0,0 +1,135 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestOAuthTransport(t *testing.T) {
+	newGitlab := func(t *testing.T) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/oauth/token" {
+				err := r.ParseForm()
+				/* Like Gitlab, only the latest refresh token works */
+				if err != nil || r.Form.Get("refresh_token") != "refresh" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]any{"access_token": "fresh", "refresh_token": "refresh2", "token_type": "Bearer", "expires_in": 7200}) //nolint:errcheck
+				return
+			}
+			if r.Header.Get("Authorization") != "Bearer fresh" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	t.Run("Refreshes an expired token and retries the request", func(t *testing.T) {
+		gitlab := newGitlab(t)
+		defer gitlab.Close()
+		SetPluginOptions(PluginOptions{GitlabUrl: gitlab.URL})
+		defer SetPluginOptions(PluginOptions{})
+
+		transport := newOAuthTransport(http.DefaultTransport, "expired", "refresh", "")
+		client := &http.Client{Transport: transport}
+		resp, err := client.Post(gitlab.URL+"/api/v4/projects", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert(t, resp.StatusCode, http.StatusOK)
+		assert(t, transport.token.AccessToken, "fresh")
+		assert(t, transport.token.RefreshToken, "refresh2")
+	})
+	t.Run("Returns the 401 when there is no refresh token", func(t *testing.T) {
+		gitlab := newGitlab(t)
+		defer gitlab.Close()
+		SetPluginOptions(PluginOptions{GitlabUrl: gitlab.URL})
+		defer SetPluginOptions(PluginOptions{})
+
+		client := &http.Client{Transport: newOAuthTransport(http.DefaultTransport, "expired", "", "")}
+		resp, err := client.Get(gitlab.URL + "/api/v4/projects")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert(t, resp.StatusCode, http.StatusUnauthorized)
+	})
+	t.Run("Saves the rotated tokens and starts from them next time", func(t *testing.T) {
+		gitlab := newGitlab(t)
+		defer gitlab.Close()
+		SetPluginOptions(PluginOptions{GitlabUrl: gitlab.URL})
+		defer SetPluginOptions(PluginOptions{})
+
+		tokenFile := filepath.Join(t.TempDir(), "gitlab.nvim", "oauth_token.json")
+		client := &http.Client{Transport: newOAuthTransport(http.DefaultTransport, "expired", "refresh", tokenFile)}
+		resp, err := client.Get(gitlab.URL + "/api/v4/projects")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		info, err := os.Stat(tokenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, info.Mode().Perm(), os.FileMode(0600))
+
+		restarted := newOAuthTransport(http.DefaultTransport, "expired", "refresh", tokenFile)
+		assert(t, restarted.token.AccessToken, "fresh")
+		assert(t, restarted.token.RefreshToken, "refresh2")
+	})
+	t.Run("Falls back to the configured refresh token when the saved one is revoked", func(t *testing.T) {
+		gitlab := newGitlab(t)
+		defer gitlab.Close()
+		SetPluginOptions(PluginOptions{GitlabUrl: gitlab.URL})
+		defer SetPluginOptions(PluginOptions{})
+
+		tokenFile := filepath.Join(t.TempDir(), "oauth_token.json")
+		err := saveOAuthToken(tokenFile, &oauth2.Token{AccessToken: "old", RefreshToken: "revoked"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		transport := newOAuthTransport(http.DefaultTransport, "expired", "refresh", tokenFile)
+		client := &http.Client{Transport: transport}
+		resp, err := client.Get(gitlab.URL + "/api/v4/projects")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert(t, resp.StatusCode, http.StatusOK)
+		assert(t, transport.token.RefreshToken, "refresh2")
+	})
+	t.Run("Refreshes on a 401 even when the request cannot be retried", func(t *testing.T) {
+		gitlab := newGitlab(t)
+		defer gitlab.Close()
+		SetPluginOptions(PluginOptions{GitlabUrl: gitlab.URL})
+		defer SetPluginOptions(PluginOptions{})
+
+		transport := newOAuthTransport(http.DefaultTransport, "expired", "refresh", "")
+		/* Wrapping the reader hides it from http.NewRequest, which then cannot set GetBody */
+		request, err := http.NewRequest(http.MethodPost, gitlab.URL+"/api/v4/projects", io.NopCloser(strings.NewReader("{}")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		assert(t, resp.StatusCode, http.StatusUnauthorized)
+		assert(t, transport.token.AccessToken, "fresh")
+	})
+}
@@ -28,6 +28,7 @@ type GetPipelineAndJobsResponse struct {
 }
 
 type PipelineManager interface {
+	MergeRequestGetter
 	ListProjectPipelines(pid interface{}, opt *gitlab.ListProjectPipelinesOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.PipelineInfo, *gitlab.Response, error)
 	ListPipelineJobs(pid interface{}, pipelineID int, opts *gitlab.ListJobsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Job, *gitlab.Response, error)
 	RetryPipelineBuild(pid interface{}, pipeline int, options ...gitlab.RequestOptionFunc) (*gitlab.Pipeline, *gitlab.Response, error)
@@ -77,22 +78,63 @@ func (a pipelineService) GetLastPipeline(commit string) (*gitlab.PipelineInfo, e
 	return pipes[0], nil
 }
 
-/* Gets the latest pipeline and job information for the current branch */
-func (a pipelineService) GetPipelineAndJobs(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	commit, err := a.gitService.GetLatestCommitOnRemote(pluginOptions.ConnectionSettings.Remote, a.gitInfo.BranchName)
+/* Gets the head pipeline of a merge request, returns an error if there is no pipeline */
+func (a pipelineService) GetMergeRequestPipeline(mergeId int) (*gitlab.PipelineInfo, error) {
+	mr, res, err := a.client.GetMergeRequest(a.projectInfo.ProjectId, mergeId, &gitlab.GetMergeRequestsOptions{})
 
 	if err != nil {
-		handleError(w, err, "Error getting commit on remote branch", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	pipeline, err := a.GetLastPipeline(commit)
+	if res.StatusCode >= 300 {
+		return nil, errors.New("could not get merge request")
+	}
+
+	p := mr.HeadPipeline
+	if p == nil {
+		return nil, fmt.Errorf("No pipeline running or available for merge request !%d", mergeId)
+	}
+
+	return &gitlab.PipelineInfo{
+		ID:        p.ID,
+		IID:       p.IID,
+		ProjectID: p.ProjectID,
+		Status:    p.Status,
+		Source:    p.Source,
+		Ref:       p.Ref,
+		SHA:       p.SHA,
+		WebURL:    p.WebURL,
+		UpdatedAt: p.UpdatedAt,
+		CreatedAt: p.CreatedAt,
+	}, nil
+}
+
+/* Gets the latest pipeline and job information for the merge request chosen with mr_iid, or else the current branch */
+func (a pipelineService) GetPipelineAndJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	if err != nil {
-		handleError(w, err, fmt.Sprintf("Failed to get latest pipeline for %s branch", a.gitInfo.BranchName), http.StatusInternalServerError)
-		return
+	var pipeline *gitlab.PipelineInfo
+	if mergeId, ok := chosenMergeId(r); ok {
+		var err error
+		pipeline, err = a.GetMergeRequestPipeline(mergeId)
+		if err != nil {
+			handleError(w, err, fmt.Sprintf("Failed to get latest pipeline for merge request !%d", mergeId), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		commit, err := a.gitService.GetLatestCommitOnRemote(pluginOptions.ConnectionSettings.Remote, a.gitInfo.BranchName)
+
+		if err != nil {
+			handleError(w, err, "Error getting commit on remote branch", http.StatusInternalServerError)
+			return
+		}
+
+		pipeline, err = a.GetLastPipeline(commit)
+
+		if err != nil {
+			handleError(w, err, fmt.Sprintf("Failed to get latest pipeline for %s branch", a.gitInfo.BranchName), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	if pipeline == nil {
@@ -19,6 +19,14 @@ func (f fakePipelineManager) ListProjectPipelines(pid interface{}, opt *gitlab.L
 	return []*gitlab.PipelineInfo{{ID: 1234}}, resp, err
 }
 
+func (f fakePipelineManager) GetMergeRequest(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestsOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &gitlab.MergeRequest{IID: mergeRequest, HeadPipeline: &gitlab.Pipeline{ID: 5678, Status: "running"}}, resp, err
+}
+
 func (f fakePipelineManager) ListPipelineJobs(pid interface{}, pipelineID int, opts *gitlab.ListJobsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Job, *gitlab.Response, error) {
 	resp, err := f.handleGitlabError()
 	if err != nil {
@@ -45,6 +53,27 @@ func TestPipelineGetter(t *testing.T) {
 		data := getSuccessData(t, svc, request)
 		assert(t, data.Message, "Pipeline retrieved")
 	})
+	t.Run("Gets the head pipeline of the merge request chosen with mr_iid", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/pipeline?mr_iid=42", nil)
+		svc := middleware(
+			pipelineService{testProjectData, fakePipelineManager{}, FakeGitManager{}},
+			withOptionalMr(testProjectData, fakeMergeRequestLister{}),
+			withMethodCheck(http.MethodGet),
+		)
+		data := decodeResponse[GetPipelineAndJobsResponse](t, svc, request)
+		assert(t, data.Pipeline.LatestPipeline.ID, 5678)
+		assert(t, data.Pipeline.LatestPipeline.Status, "running")
+	})
+	t.Run("Handles errors getting the merge request chosen with mr_iid", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/pipeline?mr_iid=42", nil)
+		svc := middleware(
+			pipelineService{testProjectData, fakePipelineManager{testBase{errFromGitlab: true}}, FakeGitManager{}},
+			withOptionalMr(testProjectData, fakeMergeRequestLister{}),
+			withMethodCheck(http.MethodGet),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkErrorFromGitlab(t, data, "Failed to get latest pipeline for merge request !42")
+	})
 	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
 		request := makeRequest(t, http.MethodGet, "/pipeline", nil)
 		svc := middleware(
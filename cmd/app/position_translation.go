@@ -0,0 +1,414 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type DiffComparer interface {
+	Compare(pid interface{}, opt *gitlab.CompareOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Compare, *gitlab.Response, error)
+}
+
+type PositionTranslator interface {
+	RevisionsGetter
+	DiffComparer
+	ListDraftNotes(pid interface{}, mergeRequest int, opt *gitlab.ListDraftNotesOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.DraftNote, *gitlab.Response, error)
+	UpdateDraftNote(pid interface{}, mergeRequest int, note int, opt *gitlab.UpdateDraftNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.DraftNote, *gitlab.Response, error)
+}
+
+type positionTranslationService struct {
+	data
+	client PositionTranslator
+}
+
+/*
+TranslatePositionsRequest holds positions captured against any version of the merge request's diff, such as
+comments that have not been sent yet. With ReanchorDraftNotes set, the user's draft notes on older versions
+are moved to the latest version too.
+*/
+type TranslatePositionsRequest struct {
+	Positions          []PositionData `json:"positions" validate:"dive"`
+	ReanchorDraftNotes bool           `json:"reanchor_draft_notes"`
+}
+
+/* TranslatedPosition is a position moved to the latest version. Outdated positions point at lines that no longer exist. */
+type TranslatedPosition struct {
+	Position PositionData `json:"position"`
+	Changed  bool         `json:"changed"`
+	Outdated bool         `json:"outdated"`
+	Reason   string       `json:"reason,omitempty"`
+}
+
+type ReanchoredDraftNote struct {
+	DraftNoteId int `json:"draft_note_id"`
+	TranslatedPosition
+	Error string `json:"error,omitempty"`
+}
+
+type TranslatePositionsResponse struct {
+	SuccessResponse
+	Version    *gitlab.MergeRequestDiffVersion `json:"version"`
+	Positions  []TranslatedPosition            `json:"positions"`
+	DraftNotes []ReanchoredDraftNote           `json:"draft_notes,omitempty"`
+}
+
+/* positionTranslationHandler maps positions from older versions of the merge request onto the latest version */
+func (a positionTranslationService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := r.Context().Value(payload("payload")).(*TranslatePositionsRequest)
+
+	versions, res, err := a.client.GetMergeRequestDiffVersions(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.GetMergeRequestDiffVersionsOptions{})
+	if err != nil {
+		handleError(w, err, "Could not get diff version info", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not get diff version info", res.StatusCode)
+		return
+	}
+
+	if len(versions) == 0 {
+		handleError(w, errors.New("merge request has no diff versions"), "Could not get diff version info", http.StatusNotFound)
+		return
+	}
+
+	/* Gitlab lists the versions newest first */
+	translator := newPositionTranslator(a.client, a.projectInfo.ProjectId, versions[0], a.compareCache)
+
+	translated := make([]TranslatedPosition, 0, len(payload.Positions))
+	for _, position := range payload.Positions {
+		t, err := translator.translate(position)
+		if err != nil {
+			handleError(w, err, "Could not translate positions", http.StatusInternalServerError)
+			return
+		}
+		translated = append(translated, t)
+	}
+
+	var draftNotes []ReanchoredDraftNote
+	if payload.ReanchorDraftNotes {
+		draftNotes, err = a.reanchorDraftNotes(translator, a.mergeId(r))
+		if err != nil {
+			handleError(w, err, "Could not re-anchor draft notes", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := TranslatePositionsResponse{
+		SuccessResponse: SuccessResponse{Message: "Positions translated"},
+		Version:         versions[0],
+		Positions:       translated,
+		DraftNotes:      draftNotes,
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/*
+reanchorDraftNotes moves draft notes left on older versions to the latest version. Outdated drafts are left
+where they are and reported, as are drafts Gitlab refused to update.
+*/
+func (a positionTranslationService) reanchorDraftNotes(translator *positionTranslator, mergeId int) ([]ReanchoredDraftNote, error) {
+	drafts, res, err := a.client.ListDraftNotes(a.projectInfo.ProjectId, mergeId, &gitlab.ListDraftNotesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("listing draft notes returned status %d", res.StatusCode)
+	}
+
+	reanchored := []ReanchoredDraftNote{}
+	for _, draft := range drafts {
+		if draft.Position == nil || draft.Position.HeadSHA == translator.latest.HeadCommitSHA {
+			continue
+		}
+
+		t, err := translator.translate(positionDataFromNote(draft.Position))
+		if err != nil {
+			return nil, err
+		}
+
+		result := ReanchoredDraftNote{DraftNoteId: draft.ID, TranslatedPosition: t}
+		if t.Changed && !t.Outdated {
+			opt := &gitlab.UpdateDraftNoteOptions{Position: buildCommentPosition(DraftNoteWithPosition{t.Position})}
+			_, res, err := a.client.UpdateDraftNote(a.projectInfo.ProjectId, mergeId, draft.ID, opt, withRetries())
+			switch {
+			case err != nil:
+				result.Error = err.Error()
+			case res.StatusCode >= 300:
+				result.Error = fmt.Sprintf("updating draft note returned status %d", res.StatusCode)
+			}
+		}
+		reanchored = append(reanchored, result)
+	}
+
+	return reanchored, nil
+}
+
+/*
+positionTranslator maps positions onto the latest version, comparing each older commit to it at most once. The
+comparisons are also shared through the cache, when there is one, with later translations.
+*/
+type positionTranslator struct {
+	client    DiffComparer
+	projectId string
+	latest    *gitlab.MergeRequestDiffVersion
+	compares  map[[2]string]*gitlab.Compare
+	cache     *compareCache
+}
+
+func newPositionTranslator(client DiffComparer, projectId string, latest *gitlab.MergeRequestDiffVersion, cache *compareCache) *positionTranslator {
+	return &positionTranslator{
+		client:    client,
+		projectId: projectId,
+		latest:    latest,
+		compares:  make(map[[2]string]*gitlab.Compare),
+		cache:     cache,
+	}
+}
+
+/*
+translate moves a position to the latest version. New lines are followed through the changes between the
+position's head commit and the latest head commit, and old lines through the changes between the two base
+commits, which only differ when the source branch was rebased.
+*/
+func (t *positionTranslator) translate(position PositionData) (TranslatedPosition, error) {
+	result := TranslatedPosition{Position: position}
+
+	if position.FileName == "" {
+		return result, nil
+	}
+
+	if position.HeadCommitSHA == t.latest.HeadCommitSHA && position.BaseCommitSHA == t.latest.BaseCommitSHA {
+		return result, nil
+	}
+
+	translated := position
+	translated.HeadCommitSHA = t.latest.HeadCommitSHA
+	translated.BaseCommitSHA = t.latest.BaseCommitSHA
+	translated.StartCommitSHA = t.latest.StartCommitSHA
+	translated.LineRange = copyLineRange(position.LineRange)
+	result.Position = translated
+	result.Changed = true
+
+	oldFileName := position.OldFileName
+	if oldFileName == "" {
+		oldFileName = position.FileName
+	}
+
+	newSide, err := t.fileChanges(position.HeadCommitSHA, t.latest.HeadCommitSHA, position.FileName)
+	if err != nil {
+		return result, err
+	}
+
+	oldSide, err := t.fileChanges(position.BaseCommitSHA, t.latest.BaseCommitSHA, oldFileName)
+	if err != nil {
+		return result, err
+	}
+
+	if newSide.deleted {
+		return outdated(result, fmt.Sprintf("%s was deleted", position.FileName)), nil
+	}
+	if oldSide.deleted && position.OldLine != nil {
+		return outdated(result, fmt.Sprintf("%s was deleted from the target branch", oldFileName)), nil
+	}
+	if newSide.newPath != "" {
+		result.Position.FileName = newSide.newPath
+	}
+	if oldSide.newPath != "" && position.OldFileName != "" {
+		result.Position.OldFileName = oldSide.newPath
+	}
+
+	switch position.Type {
+	case positionTypeFile:
+		return result, nil
+	case positionTypeImage:
+		if newSide.changed {
+			return outdated(result, fmt.Sprintf("%s has changed", position.FileName)), nil
+		}
+		return result, nil
+	}
+
+	var ok bool
+	if result.Position.NewLine, ok = newSide.mapLine(position.NewLine); !ok {
+		return outdated(result, newSide.reason(*position.NewLine)), nil
+	}
+	if result.Position.OldLine, ok = oldSide.mapLine(position.OldLine); !ok {
+		return outdated(result, oldSide.reason(*position.OldLine)), nil
+	}
+
+	if lineRange := result.Position.LineRange; lineRange != nil {
+		for _, linePosition := range []*LinePosition{lineRange.StartRange, lineRange.EndRange} {
+			if linePosition == nil {
+				continue
+			}
+			if linePosition.NewLine != 0 {
+				newLine, ok := newSide.mapLine(&linePosition.NewLine)
+				if !ok {
+					return outdated(result, newSide.reason(linePosition.NewLine)), nil
+				}
+				linePosition.NewLine = *newLine
+			}
+			if linePosition.OldLine != 0 {
+				oldLine, ok := oldSide.mapLine(&linePosition.OldLine)
+				if !ok {
+					return outdated(result, oldSide.reason(linePosition.OldLine)), nil
+				}
+				linePosition.OldLine = *oldLine
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func outdated(result TranslatedPosition, reason string) TranslatedPosition {
+	result.Outdated = true
+	result.Reason = reason
+	return result
+}
+
+/* fileChanges is how a single file changed between two commits */
+type fileChanges struct {
+	path     string
+	newPath  string
+	deleted  bool
+	changed  bool
+	tooLarge bool
+	hunks    []diffHunk
+}
+
+/* mapLine follows a line through the changes, a nil line is left alone */
+func (f fileChanges) mapLine(line *int) (*int, bool) {
+	if line == nil || !f.changed {
+		return line, true
+	}
+	if f.tooLarge {
+		return nil, false
+	}
+	mapped, ok := mapLineThroughDiff(f.hunks, *line)
+	if !ok {
+		return nil, false
+	}
+	return &mapped, true
+}
+
+func (f fileChanges) reason(line int) string {
+	if f.tooLarge {
+		return fmt.Sprintf("the changes to %s are too large to follow line %d", f.path, line)
+	}
+	return fmt.Sprintf("line %d of %s no longer exists", line, f.path)
+}
+
+/* fileChanges compares two commits and picks out the changes to one file */
+func (t *positionTranslator) fileChanges(from string, to string, path string) (fileChanges, error) {
+	changes := fileChanges{path: path}
+	if from == "" || from == to {
+		return changes, nil
+	}
+
+	key := [2]string{from, to}
+	compare, ok := t.compares[key]
+	if !ok {
+		compare, ok = t.cache.get(from, to)
+	}
+	if !ok {
+		var res *gitlab.Response
+		var err error
+		compare, res, err = t.client.Compare(t.projectId, &gitlab.CompareOptions{From: gitlab.Ptr(from), To: gitlab.Ptr(to), Straight: gitlab.Ptr(true)})
+		if err != nil {
+			return changes, err
+		}
+		if res.StatusCode >= 300 {
+			return changes, fmt.Errorf("comparing %s to %s returned status %d", from, to, res.StatusCode)
+		}
+		t.cache.set(from, to, compare)
+	}
+	t.compares[key] = compare
+
+	for _, diff := range compare.Diffs {
+		if diff.OldPath != path {
+			continue
+		}
+		if diff.DeletedFile {
+			changes.deleted = true
+			return changes, nil
+		}
+		if diff.RenamedFile {
+			changes.newPath = diff.NewPath
+		}
+		if diff.Diff == "" {
+			/* A rename without edits has no diff, anything else without one was too large for Gitlab to return */
+			changes.changed = !diff.RenamedFile
+			changes.tooLarge = !diff.RenamedFile
+			return changes, nil
+		}
+
+		hunks, err := parseDiffHunks(diff.Diff)
+		if err != nil {
+			return changes, err
+		}
+		changes.changed = true
+		changes.hunks = hunks
+		return changes, nil
+	}
+
+	return changes, nil
+}
+
+func copyLineRange(lineRange *LineRange) *LineRange {
+	if lineRange == nil {
+		return nil
+	}
+	copied := &LineRange{}
+	if lineRange.StartRange != nil {
+		start := *lineRange.StartRange
+		copied.StartRange = &start
+	}
+	if lineRange.EndRange != nil {
+		end := *lineRange.EndRange
+		copied.EndRange = &end
+	}
+	return copied
+}
+
+/* positionDataFromNote converts the position Gitlab returns on notes into the position we send it */
+func positionDataFromNote(position *gitlab.NotePosition) PositionData {
+	data := PositionData{
+		FileName:       position.NewPath,
+		OldFileName:    position.OldPath,
+		HeadCommitSHA:  position.HeadSHA,
+		BaseCommitSHA:  position.BaseSHA,
+		StartCommitSHA: position.StartSHA,
+		Type:           position.PositionType,
+	}
+	if position.NewLine != 0 {
+		data.NewLine = gitlab.Ptr(position.NewLine)
+	}
+	if position.OldLine != 0 {
+		data.OldLine = gitlab.Ptr(position.OldLine)
+	}
+	if position.LineRange != nil && position.LineRange.StartRange != nil && position.LineRange.EndRange != nil {
+		data.LineRange = &LineRange{
+			StartRange: &LinePosition{
+				Type:    position.LineRange.StartRange.Type,
+				OldLine: position.LineRange.StartRange.OldLine,
+				NewLine: position.LineRange.StartRange.NewLine,
+			},
+			EndRange: &LinePosition{
+				Type:    position.LineRange.EndRange.Type,
+				OldLine: position.LineRange.EndRange.OldLine,
+				NewLine: position.LineRange.EndRange.NewLine,
+			},
+		}
+	}
+	return data
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakePositionTranslator struct {
+	fakeDraftNoteManager
+	diffs []*gitlab.Diff
+}
+
+func (f fakePositionTranslator) GetMergeRequestDiffVersions(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestDiffVersionsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.MergeRequestDiffVersion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*gitlab.MergeRequestDiffVersion{
+		{ID: 2, HeadCommitSHA: "head2", BaseCommitSHA: "base", StartCommitSHA: "start"},
+		{ID: 1, HeadCommitSHA: "head1", BaseCommitSHA: "base", StartCommitSHA: "start"},
+	}, resp, err
+}
+
+func (f fakePositionTranslator) Compare(pid interface{}, opt *gitlab.CompareOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Compare, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gitlab.Compare{Diffs: f.diffs}, resp, err
+}
+
+func (f fakePositionTranslator) ListDraftNotes(pid interface{}, mergeRequest int, opt *gitlab.ListDraftNotesOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.DraftNote, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*gitlab.DraftNote{
+		{ID: 1, Position: &gitlab.NotePosition{NewPath: "main.go", OldPath: "main.go", HeadSHA: "head1", BaseSHA: "base", PositionType: "text", NewLine: 5}},
+		{ID: 2, Position: &gitlab.NotePosition{NewPath: "main.go", OldPath: "main.go", HeadSHA: "head2", BaseSHA: "base", PositionType: "text", NewLine: 5}},
+		{ID: 3},
+	}, resp, err
+}
+
+func TestPositionTranslationHandler(t *testing.T) {
+	diffs := []*gitlab.Diff{
+		{OldPath: "main.go", NewPath: "main.go", Diff: "@@ -1,3 +1,4 @@\n a\n+new\n b\n-c\n+C\n"},
+		{OldPath: "old.go", NewPath: "renamed.go", RenamedFile: true},
+		{OldPath: "deleted.go", NewPath: "deleted.go", DeletedFile: true},
+	}
+	oldPosition := func(file string, line int) PositionData {
+		return PositionData{FileName: file, NewLine: gitlab.Ptr(line), HeadCommitSHA: "head1", BaseCommitSHA: "base", Type: "text"}
+	}
+	translate := func(t *testing.T, client fakePositionTranslator, body TranslatePositionsRequest) (TranslatePositionsResponse, int) {
+		request := makeRequest(t, http.MethodPost, "/mr/positions/translate", body)
+		svc := middleware(
+			positionTranslationService{testProjectData, client},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[TranslatePositionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		res := httptest.NewRecorder()
+		svc.ServeHTTP(res, request)
+
+		var data TranslatePositionsResponse
+		err := json.Unmarshal(res.Body.Bytes(), &data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data, res.Code
+	}
+
+	t.Run("Moves lines to the latest version and flags removed lines", func(t *testing.T) {
+		data, _ := translate(t, fakePositionTranslator{diffs: diffs}, TranslatePositionsRequest{Positions: []PositionData{
+			oldPosition("main.go", 2),
+			oldPosition("main.go", 3),
+			oldPosition("renamed.go", 1),
+			oldPosition("deleted.go", 1),
+		}})
+		assert(t, data.Message, "Positions translated")
+		assert(t, len(data.Positions), 4)
+
+		assert(t, *data.Positions[0].Position.NewLine, 3)
+		assert(t, data.Positions[0].Position.HeadCommitSHA, "head2")
+		assert(t, data.Positions[0].Outdated, false)
+
+		assert(t, data.Positions[1].Outdated, true)
+		assert(t, data.Positions[1].Reason, "line 3 of main.go no longer exists")
+
+		assert(t, data.Positions[3].Outdated, true)
+	})
+	t.Run("Follows renamed files", func(t *testing.T) {
+		data, _ := translate(t, fakePositionTranslator{diffs: diffs}, TranslatePositionsRequest{Positions: []PositionData{oldPosition("old.go", 7)}})
+		assert(t, data.Positions[0].Position.FileName, "renamed.go")
+		assert(t, *data.Positions[0].Position.NewLine, 7)
+	})
+	t.Run("Leaves positions on the latest version alone", func(t *testing.T) {
+		position := oldPosition("main.go", 3)
+		position.HeadCommitSHA = "head2"
+		data, _ := translate(t, fakePositionTranslator{diffs: diffs}, TranslatePositionsRequest{Positions: []PositionData{position}})
+		assert(t, data.Positions[0].Changed, false)
+		assert(t, *data.Positions[0].Position.NewLine, 3)
+	})
+	t.Run("Re-anchors draft notes from older versions", func(t *testing.T) {
+		data, _ := translate(t, fakePositionTranslator{diffs: diffs}, TranslatePositionsRequest{ReanchorDraftNotes: true})
+		assert(t, len(data.DraftNotes), 1)
+		assert(t, data.DraftNotes[0].DraftNoteId, 1)
+		assert(t, *data.DraftNotes[0].Position.NewLine, 6)
+		assert(t, data.DraftNotes[0].Error, "")
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/positions/translate", TranslatePositionsRequest{})
+		svc := middleware(
+			positionTranslationService{testProjectData, fakePositionTranslator{fakeDraftNoteManager: fakeDraftNoteManager{testBase{errFromGitlab: true}}}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[TranslatePositionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkErrorFromGitlab(t, data, "Could not get diff version info")
+	})
+	t.Run("Handles non-200s from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/positions/translate", TranslatePositionsRequest{})
+		svc := middleware(
+			positionTranslationService{testProjectData, fakePositionTranslator{fakeDraftNoteManager: fakeDraftNoteManager{testBase{status: http.StatusSeeOther}}}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[TranslatePositionsRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkNon200(t, data, "Could not get diff version info", "/mr/positions/translate")
+	})
+}
@@ -2,6 +2,8 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -12,15 +14,26 @@ type ReplyRequest struct {
 	DiscussionId string `json:"discussion_id" validate:"required"`
 	Reply        string `json:"reply" validate:"required"`
 	IsDraft      bool   `json:"is_draft"`
+	Internal     bool   `json:"internal"`
+	Resolved     *bool  `json:"resolved,omitempty"`
 }
 
+/*
+ReplyResponse carries either the note or, for draft replies, the draft note that was created. When the reply
+also resolved or unresolved the discussion, the updated discussion is included.
+*/
 type ReplyResponse struct {
 	SuccessResponse
-	Note *gitlab.Note `json:"note"`
+	IsDraft    bool               `json:"is_draft"`
+	Note       *gitlab.Note       `json:"note,omitempty"`
+	DraftNote  *gitlab.DraftNote  `json:"draft_note,omitempty"`
+	Discussion *gitlab.Discussion `json:"discussion,omitempty"`
 }
 
 type ReplyManager interface {
 	AddMergeRequestDiscussionNote(interface{}, int, string, *gitlab.AddMergeRequestDiscussionNoteOptions, ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error)
+	DraftNoteManager
+	DiscussionResolver
 }
 
 type replyService struct {
@@ -28,17 +41,25 @@ type replyService struct {
 	client ReplyManager
 }
 
-/* replyHandler sends a reply to a note or comment */
+/*
+replyHandler sends a reply to a note or comment, or saves it as a draft note when is_draft is set. If resolved is
+set the discussion is resolved or unresolved once the reply has been posted.
+*/
 func (a replyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	replyRequest := r.Context().Value(payload("payload")).(*ReplyRequest)
 
+	if replyRequest.IsDraft {
+		a.createDraftReply(w, r, replyRequest)
+		return
+	}
+
 	now := time.Now()
 	options := gitlab.AddMergeRequestDiscussionNoteOptions{
 		Body:      gitlab.Ptr(replyRequest.Reply),
 		CreatedAt: &now,
 	}
 
-	note, res, err := a.client.AddMergeRequestDiscussionNote(a.projectInfo.ProjectId, a.projectInfo.MergeId, replyRequest.DiscussionId, &options)
+	note, res, err := a.client.AddMergeRequestDiscussionNote(a.projectInfo.ProjectId, a.mergeId(r), replyRequest.DiscussionId, &options, internalNoteOptions(replyRequest.Internal)...)
 
 	if err != nil {
 		handleError(w, err, "Could not leave reply", http.StatusInternalServerError)
@@ -50,12 +71,82 @@ func (a replyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	response := ReplyResponse{
 		SuccessResponse: SuccessResponse{Message: "Replied to comment"},
 		Note:            note,
 	}
 
+	if replyRequest.Resolved != nil {
+		friendlyName := "unresolve"
+		if *replyRequest.Resolved {
+			friendlyName = "resolve"
+		}
+
+		discussion, res, err := a.client.ResolveMergeRequestDiscussion(
+			a.projectInfo.ProjectId,
+			a.mergeId(r),
+			replyRequest.DiscussionId,
+			&gitlab.ResolveMergeRequestDiscussionOptions{Resolved: replyRequest.Resolved},
+			withRetries(),
+		)
+
+		if err != nil {
+			handleError(w, err, fmt.Sprintf("Replied to comment but could not %s discussion", friendlyName), http.StatusInternalServerError)
+			return
+		}
+
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, fmt.Sprintf("Replied to comment but could not %s discussion", friendlyName), res.StatusCode)
+			return
+		}
+
+		response.Message = fmt.Sprintf("Replied to comment and %sd discussion", friendlyName)
+		response.Discussion = discussion
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/*
+createDraftReply saves the reply as a draft note on the discussion, to be published with the rest of the review.
+Gitlab can only resolve the discussion when the draft is published, it has no way to unresolve one.
+*/
+func (a replyService) createDraftReply(w http.ResponseWriter, r *http.Request, replyRequest *ReplyRequest) {
+	if replyRequest.Resolved != nil && !*replyRequest.Resolved {
+		handleError(w, errors.New("draft replies can only resolve a discussion"), "Could not create draft reply", http.StatusBadRequest)
+		return
+	}
+
+	options := gitlab.CreateDraftNoteOptions{
+		Note:                  gitlab.Ptr(replyRequest.Reply),
+		InReplyToDiscussionID: gitlab.Ptr(replyRequest.DiscussionId),
+		ResolveDiscussion:     replyRequest.Resolved,
+	}
+
+	draftNote, res, err := a.client.CreateDraftNote(a.projectInfo.ProjectId, a.mergeId(r), &options, internalNoteOptions(replyRequest.Internal)...)
+
+	if err != nil {
+		handleError(w, err, "Could not create draft reply", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not create draft reply", res.StatusCode)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := ReplyResponse{
+		SuccessResponse: SuccessResponse{Message: "Draft reply created"},
+		IsDraft:         true,
+		DraftNote:       draftNote,
+	}
+
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
@@ -1,23 +1,34 @@
 package app
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/xanzy/go-gitlab"
 )
 
 type fakeReplyManager struct {
-	testBase
+	fakeDraftNoteManager
 }
 
-func (f fakeReplyManager) AddMergeRequestDiscussionNote(interface{}, int, string, *gitlab.AddMergeRequestDiscussionNoteOptions, ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error) {
+func (f fakeReplyManager) AddMergeRequestDiscussionNote(pid interface{}, mergeRequest int, discussion string, opt *gitlab.AddMergeRequestDiscussionNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Note, *gitlab.Response, error) {
 	resp, err := f.handleGitlabError()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return &gitlab.Note{}, resp, err
+	return &gitlab.Note{Internal: requestsInternalNote(options)}, resp, err
+}
+
+func (f fakeReplyManager) ResolveMergeRequestDiscussion(pid interface{}, mergeRequest int, discussion string, opt *gitlab.ResolveMergeRequestDiscussionOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Discussion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gitlab.Discussion{ID: discussion}, resp, err
 }
 
 func TestReplyHandler(t *testing.T) {
@@ -36,7 +47,7 @@ func TestReplyHandler(t *testing.T) {
 	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
 		request := makeRequest(t, http.MethodPost, "/mr/reply", testReplyRequest)
 		svc := middleware(
-			replyService{testProjectData, fakeReplyManager{testBase{errFromGitlab: true}}},
+			replyService{testProjectData, fakeReplyManager{fakeDraftNoteManager{testBase{errFromGitlab: true}}}},
 			withMr(testProjectData, fakeMergeRequestLister{}),
 			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
 			withMethodCheck(http.MethodPost),
@@ -48,7 +59,7 @@ func TestReplyHandler(t *testing.T) {
 	t.Run("Handles non-200s from Gitlab client", func(t *testing.T) {
 		request := makeRequest(t, http.MethodPost, "/mr/reply", testReplyRequest)
 		svc := middleware(
-			replyService{testProjectData, fakeReplyManager{testBase{status: http.StatusSeeOther}}},
+			replyService{testProjectData, fakeReplyManager{fakeDraftNoteManager{testBase{status: http.StatusSeeOther}}}},
 			withMr(testProjectData, fakeMergeRequestLister{}),
 			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
 			withMethodCheck(http.MethodPost),
@@ -56,4 +67,70 @@ func TestReplyHandler(t *testing.T) {
 		data, _ := getFailData(t, svc, request)
 		checkNon200(t, data, "Could not leave reply", "/mr/reply")
 	})
+	t.Run("Creates a draft reply when is_draft is set", func(t *testing.T) {
+		draftReplyRequest := ReplyRequest{DiscussionId: "abc123", Reply: "Some Reply", IsDraft: true}
+		request := makeRequest(t, http.MethodPost, "/mr/reply", draftReplyRequest)
+		svc := middleware(
+			replyService{testProjectData, fakeReplyManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getSuccessData(t, svc, request)
+		assert(t, data.Message, "Draft reply created")
+	})
+	t.Run("Handles errors from Gitlab client when creating a draft reply", func(t *testing.T) {
+		draftReplyRequest := ReplyRequest{DiscussionId: "abc123", Reply: "Some Reply", IsDraft: true}
+		request := makeRequest(t, http.MethodPost, "/mr/reply", draftReplyRequest)
+		svc := middleware(
+			replyService{testProjectData, fakeReplyManager{fakeDraftNoteManager{testBase{errFromGitlab: true}}}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkErrorFromGitlab(t, data, "Could not create draft reply")
+	})
+	t.Run("Resolves the discussion after replying", func(t *testing.T) {
+		resolveReplyRequest := ReplyRequest{DiscussionId: "abc123", Reply: "Done", Resolved: gitlab.Ptr(true)}
+		request := makeRequest(t, http.MethodPost, "/mr/reply", resolveReplyRequest)
+		svc := middleware(
+			replyService{testProjectData, fakeReplyManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getSuccessData(t, svc, request)
+		assert(t, data.Message, "Replied to comment and resolved discussion")
+	})
+	t.Run("Sends an internal reply", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/reply", ReplyRequest{DiscussionId: "abc123", Reply: "Some comment", Internal: true})
+		svc := middleware(
+			replyService{testProjectData, fakeReplyManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		res := httptest.NewRecorder()
+		svc.ServeHTTP(res, request)
+		var data ReplyResponse
+		err := json.Unmarshal(res.Body.Bytes(), &data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, data.Note.Internal, true)
+	})
+	t.Run("Rejects draft replies that unresolve the discussion", func(t *testing.T) {
+		draftReplyRequest := ReplyRequest{DiscussionId: "abc123", Reply: "Not done", IsDraft: true, Resolved: gitlab.Ptr(false)}
+		request := makeRequest(t, http.MethodPost, "/mr/reply", draftReplyRequest)
+		svc := middleware(
+			replyService{testProjectData, fakeReplyManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, status := getFailData(t, svc, request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Details, "draft replies can only resolve a discussion")
+	})
 }
@@ -28,9 +28,10 @@ func (a discussionsResolutionService) ServeHTTP(w http.ResponseWriter, r *http.R
 
 	_, res, err := a.client.ResolveMergeRequestDiscussion(
 		a.projectInfo.ProjectId,
-		a.projectInfo.MergeId,
+		a.mergeId(r),
 		payload.DiscussionID,
 		&gitlab.ResolveMergeRequestDiscussionOptions{Resolved: &payload.Resolved},
+		withRetries(),
 	)
 
 	friendlyName := "unresolve"
@@ -34,9 +34,9 @@ type reviewerService struct {
 func (a reviewerService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	payload := r.Context().Value(payload("payload")).(*ReviewerUpdateRequest)
 
-	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, &gitlab.UpdateMergeRequestOptions{
+	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.UpdateMergeRequestOptions{
 		ReviewerIDs: &payload.Ids,
-	})
+	}, withRetries())
 
 	if err != nil {
 		handleError(w, err, "Could not modify merge request reviewers", http.StatusInternalServerError)
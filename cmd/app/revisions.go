@@ -27,7 +27,7 @@ a precursor API call for other functionality
 */
 func (a revisionsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	versionInfo, res, err := a.client.GetMergeRequestDiffVersions(a.projectInfo.ProjectId, a.projectInfo.MergeId, &gitlab.GetMergeRequestDiffVersionsOptions{})
+	versionInfo, res, err := a.client.GetMergeRequestDiffVersions(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.GetMergeRequestDiffVersionsOptions{})
 	if err != nil {
 		handleError(w, err, "Could not get diff version info", http.StatusInternalServerError)
 		return
@@ -19,7 +19,7 @@ type mergeRequestRevokerService struct {
 /* revokeHandler revokes approval for the current merge request */
 func (a mergeRequestRevokerService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	res, err := a.client.UnapproveMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, nil, nil)
+	res, err := a.client.UnapproveMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), nil, nil)
 
 	if err != nil {
 		handleError(w, err, "Could not revoke approval", http.StatusInternalServerError)
@@ -1,11 +1,16 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/harrisoncramer/gitlab.nvim/cmd/app/git"
@@ -20,6 +25,13 @@ func StartServer(client *Client, projectInfo *ProjectInfo, GitInfo git.GitData)
 
 	s := shutdownService{
 		sigCh: make(chan os.Signal, 1),
+		done:  make(chan struct{}),
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not generate server secret: %s\n", err)
+		os.Exit(1)
 	}
 
 	fr := attachmentReader{}
@@ -28,6 +40,7 @@ func StartServer(client *Client, projectInfo *ProjectInfo, GitInfo git.GitData)
 		projectInfo,
 		&s,
 		func(a *data) error { a.projectInfo = projectInfo; return nil },
+		func(a *data) error { a.secret = secret; return nil },
 		func(a *data) error { a.gitInfo = &GitInfo; return nil },
 		func(a *data) error { err := attachEmojis(a, fr); return err },
 	)
@@ -46,18 +59,26 @@ func StartServer(client *Client, projectInfo *ProjectInfo, GitInfo git.GitData)
 		}
 	}()
 
-	port := l.Addr().(*net.TCPAddr).Port
-	err := checkServer(port)
+	err = checkServer(l, secret)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Server did not respond: %s\n", err)
 		os.Exit(1)
 	}
 
-	/* This print is detected by the Lua code */
-	fmt.Println("Server started on port: ", port)
+	/* These prints are detected by the Lua code, which sends the secret back with every request */
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		fmt.Println("Server started on port: ", addr.Port, "with secret:", secret)
+	} else {
+		fmt.Println("Server started on socket: ", l.Addr().String(), "with secret:", secret)
+	}
 
 	/* Handles shutdown requests */
 	s.WatchForShutdown(server)
+
+	/* Closing the listener normally unlinks the socket file, but make sure it is gone */
+	if sl, ok := l.(socketListener); ok {
+		sl.removeSocket()
+	}
 }
 
 /*
@@ -67,9 +88,13 @@ file reader functionality
 */
 
 type data struct {
-	projectInfo *ProjectInfo
-	gitInfo     *git.GitData
-	emojiMap    EmojiMap
+	projectInfo   *ProjectInfo
+	gitInfo       *git.GitData
+	emojiMap      EmojiMap
+	emojiCache    *noteEmojiCache
+	compareCache  *compareCache
+	mergeRequests *mergeRequestRegistry
+	secret        string
 }
 
 type optFunc func(a *data) error
@@ -78,8 +103,11 @@ func CreateRouter(gitlabClient *Client, projectInfo *ProjectInfo, s *shutdownSer
 	m := http.NewServeMux()
 
 	d := data{
-		projectInfo: &ProjectInfo{},
-		gitInfo:     &git.GitData{},
+		projectInfo:   &ProjectInfo{},
+		gitInfo:       &git.GitData{},
+		emojiCache:    newNoteEmojiCache(),
+		compareCache:  newCompareCache(),
+		mergeRequests: newMergeRequestRegistry(),
 	}
 
 	/* Mutates the API struct as necessary with configuration functions */
@@ -111,6 +139,12 @@ func CreateRouter(gitlabClient *Client, projectInfo *ProjectInfo, s *shutdownSer
 		}),
 		withMethodCheck(http.MethodPost, http.MethodDelete, http.MethodPatch),
 	))
+	m.HandleFunc("/mr/comment/suggestion", middleware(
+		suggestionCommentService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostSuggestionRequest]}),
+		withMethodCheck(http.MethodPost),
+	))
 	m.HandleFunc("/mr/merge", middleware(
 		mergeRequestAccepterService{d, gitlabClient},
 		withMr(d, gitlabClient),
@@ -129,6 +163,29 @@ func CreateRouter(gitlabClient *Client, projectInfo *ProjectInfo, s *shutdownSer
 		withPayloadValidation(methodToPayload{http.MethodPut: newPayload[DiscussionResolveRequest]}),
 		withMethodCheck(http.MethodPut),
 	))
+	m.HandleFunc("/mr/export", middleware(
+		exportService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ExportRequest]}),
+		withMethodCheck(http.MethodPost),
+	))
+	m.HandleFunc("/mr/discussions/resolve/bulk", middleware(
+		bulkResolveService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPut: newPayload[BulkResolveRequest]}),
+		withMethodCheck(http.MethodPut),
+	))
+	m.HandleFunc("/mr/commits", middleware(
+		commitsService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withMethodCheck(http.MethodGet),
+	))
+	m.HandleFunc("/mr/commits/", middleware(
+		commitsService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostCommitDiscussionRequest]}),
+		withMethodCheck(http.MethodGet, http.MethodPost),
+	))
 	m.HandleFunc("/mr/info", middleware(
 		infoService{d, gitlabClient},
 		withMr(d, gitlabClient),
@@ -157,12 +214,36 @@ func CreateRouter(gitlabClient *Client, projectInfo *ProjectInfo, s *shutdownSer
 		withMr(d, gitlabClient),
 		withMethodCheck(http.MethodGet),
 	))
+	m.HandleFunc("/mr/diffs", middleware(
+		diffsService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[DiffsRequest]}),
+		withMethodCheck(http.MethodPost),
+	))
+	m.HandleFunc("/mr/interdiff", middleware(
+		interdiffService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[InterdiffRequest]}),
+		withMethodCheck(http.MethodPost),
+	))
+	m.HandleFunc("/mr/positions/translate", middleware(
+		positionTranslationService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[TranslatePositionsRequest]}),
+		withMethodCheck(http.MethodPost),
+	))
 	m.HandleFunc("/mr/reply", middleware(
 		replyService{d, gitlabClient},
 		withMr(d, gitlabClient),
 		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ReplyRequest]}),
 		withMethodCheck(http.MethodPost),
 	))
+	m.HandleFunc("/mr/suggestions", middleware(
+		suggestionsService{d, gitlabClient},
+		withMr(d, gitlabClient),
+		withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ApplySuggestionsRequest]}),
+		withMethodCheck(http.MethodGet, http.MethodPost),
+	))
 	m.HandleFunc("/mr/label", middleware(
 		labelService{d, gitlabClient},
 		withMr(d, gitlabClient),
@@ -194,12 +275,18 @@ func CreateRouter(gitlabClient *Client, projectInfo *ProjectInfo, s *shutdownSer
 	))
 	m.HandleFunc("/pipeline", middleware(
 		pipelineService{d, gitlabClient, git.Git{}},
+		withOptionalMr(d, gitlabClient),
 		withMethodCheck(http.MethodGet),
 	))
 	m.HandleFunc("/pipeline/trigger/", middleware(
 		pipelineService{d, gitlabClient, git.Git{}},
 		withMethodCheck(http.MethodPost),
 	))
+	m.HandleFunc("/events", middleware(
+		eventsService{data: d, client: gitlabClient, hub: newEventsHub(0, s.done)},
+		withMr(d, gitlabClient),
+		withMethodCheck(http.MethodGet),
+	))
 	m.HandleFunc("/users/me", middleware(
 		meService{d, gitlabClient},
 		withMethodCheck(http.MethodGet),
@@ -244,13 +331,32 @@ func CreateRouter(gitlabClient *Client, projectInfo *ProjectInfo, s *shutdownSer
 		fmt.Fprintln(w, "pong")
 	})
 
-	return LoggingServer{handler: m}
+	return LoggingServer{handler: middleware(withMrPathRewrite(m), withSecretCheck(d.secret))}
 }
 
 /* checkServer pings the server repeatedly for 1 full second after startup in order to notify the plugin that the server is ready */
-func checkServer(port int) error {
+func checkServer(l net.Listener, secret string) error {
+	client := http.DefaultClient
+	url := "http://" + l.Addr().String() + "/ping"
+
+	if l.Addr().Network() == "unix" {
+		client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", l.Addr().String())
+				},
+			},
+		}
+		url = "http://localhost/ping"
+	}
+
 	for i := 0; i < 10; i++ {
-		resp, err := http.Get("http://localhost:" + fmt.Sprintf("%d", port) + "/ping")
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(secretHeader, secret)
+		resp, err := client.Do(req)
 		if resp != nil && resp.StatusCode == 200 && err == nil {
 			return nil
 		}
@@ -260,8 +366,30 @@ func checkServer(port int) error {
 	return errors.New("could not start server")
 }
 
-/* Creates a TCP listener on the port specified by the user or a random port */
+/* generateSecret creates the random secret that clients must send with every request for this session */
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+/*
+Creates a Unix socket listener if the user has configured a socket path, otherwise a TCP listener on the
+port specified by the user or a random port
+*/
 func createListener() (l net.Listener) {
+	if pluginOptions.SocketPath != "" {
+		l, err := createSocketListener(pluginOptions.SocketPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting server: %s\n", err)
+			os.Exit(1)
+		}
+		return l
+	}
+
 	addr := fmt.Sprintf("localhost:%d", pluginOptions.Port)
 	l, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -271,3 +399,105 @@ func createListener() (l net.Listener) {
 
 	return l
 }
+
+/*
+Creates a Unix socket listener that only the current user can connect to, replacing a stale socket left by an
+earlier run. A socket another server is still listening on, or anything else at the path, is left alone. The
+socket is bound inside a private directory and only moved to the path once its mode is set, so nobody else can
+connect to it in between.
+*/
+func createSocketListener(path string) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeSocket == 0:
+		return nil, fmt.Errorf("%s already exists and is not a socket", path)
+	case err == nil:
+		err = removeStaleSocket(path)
+		if err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	/* MkdirTemp creates the directory with mode 0700. The names are short to stay within the socket path limit. */
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".gl")
+	if err != nil {
+		return nil, fmt.Errorf("could not create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	privatePath := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", privatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	/* The socket is unlinked by socketListener.Close once it has been moved */
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	err = os.Chmod(privatePath, 0600)
+	if err == nil {
+		err = os.Rename(privatePath, path)
+	}
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("could not set up socket: %w", err)
+	}
+
+	info, err = os.Lstat(path)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("could not set up socket: %w", err)
+	}
+
+	return socketListener{Listener: l, path: path, info: info}, nil
+}
+
+/* removeStaleSocket removes the socket at the path unless a server is still listening on it */
+func removeStaleSocket(path string) error {
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is already in use by another server", path)
+	}
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("could not check whether socket %s is in use: %w", path, err)
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return fmt.Errorf("could not remove stale socket: %w", err)
+	}
+
+	return nil
+}
+
+/*
+socketListener reports and cleans up the path the socket was moved to rather than the one it was bound at. The
+socket is only removed while it is still the file this server created, so a server that has since taken over the
+path keeps its socket.
+*/
+type socketListener struct {
+	net.Listener
+	path string
+	info os.FileInfo
+}
+
+func (l socketListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+func (l socketListener) Close() error {
+	err := l.Listener.Close()
+	l.removeSocket()
+	return err
+}
+
+func (l socketListener) removeSocket() {
+	info, err := os.Lstat(l.path)
+	if err == nil && os.SameFile(info, l.info) {
+		os.Remove(l.path) //nolint:errcheck
+	}
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSocketListener(t *testing.T) {
+	t.Run("Creates a socket only the current user can use and answers pings on it", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gitlab.sock")
+		l, err := createSocketListener(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, info.Mode().Perm(), os.FileMode(0600))
+		assert(t, l.Addr().String(), path)
+
+		/* Only the socket is left behind in the directory */
+		entries, err := os.ReadDir(filepath.Dir(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, len(entries), 1)
+
+		server := &http.Server{Handler: middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}), withSecretCheck("abc123"))}
+		go server.Serve(l) //nolint:errcheck
+		defer server.Close()
+
+		err = checkServer(l, "abc123")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("Replaces a stale socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gitlab.sock")
+		stale, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		l, err := createSocketListener(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+		_, err = os.Stat(path)
+		assert(t, os.IsNotExist(err), true)
+	})
+	t.Run("Does not take over a socket another server is listening on", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gitlab.sock")
+		running, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer running.Close()
+
+		_, err = createSocketListener(path)
+		assert(t, err != nil, true)
+		assert(t, strings.Contains(err.Error(), "already in use"), true)
+
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+	t.Run("Does not remove a socket that has since been replaced", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gitlab.sock")
+		l, err := createSocketListener(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer other.Close()
+
+		l.Close()
+		_, err = os.Stat(path)
+		assert(t, err == nil, true)
+	})
+	t.Run("Leaves files that are not sockets alone", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notes.txt")
+		err := os.WriteFile(path, []byte("keep me"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = createSocketListener(path)
+		assert(t, err != nil, true)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, string(content), "keep me")
+	})
+}
@@ -22,11 +22,15 @@ type ShutdownHandler interface {
 
 type shutdownService struct {
 	sigCh chan os.Signal
+	done  chan struct{} // Closed on shutdown so that long-lived handlers (such as event streams) can exit
 }
 
 func (s shutdownService) WatchForShutdown(server *http.Server) {
 	/* Handles shutdown requests */
 	<-s.sigCh
+	if s.done != nil {
+		close(s.done)
+	}
 	err := server.Shutdown(context.Background())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Server could not shut down gracefully: %s\n", err)
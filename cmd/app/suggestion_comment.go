@@ -0,0 +1,194 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type SuggestionCommentManager interface {
+	CreateMergeRequestDiscussion(pid interface{}, mergeRequest int, opt *gitlab.CreateMergeRequestDiscussionOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Discussion, *gitlab.Response, error)
+	CreateDraftNote(pid interface{}, mergeRequest int, opt *gitlab.CreateDraftNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.DraftNote, *gitlab.Response, error)
+}
+
+type suggestionCommentService struct {
+	data
+	client SuggestionCommentManager
+}
+
+/*
+PostSuggestionRequest turns a local edit into a suggestion. The position is the original lines being replaced,
+either a single line or a line range, and Suggestion is the text to replace them with. An empty suggestion
+deletes the lines. Comment is optional text shown above the suggestion.
+*/
+type PostSuggestionRequest struct {
+	Comment    string `json:"comment"`
+	Suggestion string `json:"suggestion"`
+	IsDraft    bool   `json:"is_draft"`
+	PositionData
+}
+
+type SuggestionCommentResponse struct {
+	SuccessResponse
+	IsDraft    bool               `json:"is_draft"`
+	Comment    *gitlab.Note       `json:"note,omitempty"`
+	Discussion *gitlab.Discussion `json:"discussion,omitempty"`
+	DraftNote  *gitlab.DraftNote  `json:"draft_note,omitempty"`
+}
+
+/* suggestionCommentHandler posts a suggestion built from the payload as a comment or draft note on the diff */
+func (a suggestionCommentService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := r.Context().Value(payload("payload")).(*PostSuggestionRequest)
+
+	body, err := buildSuggestionBody(payload)
+	if err != nil {
+		handleError(w, err, "Invalid suggestion", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Type == "" {
+		payload.Type = positionTypeText
+	}
+	position := buildCommentPosition(CommentWithPosition{payload.PositionData})
+
+	if payload.IsDraft {
+		a.postSuggestionDraftNote(w, r, body, position)
+		return
+	}
+
+	opt := gitlab.CreateMergeRequestDiscussionOptions{
+		Body:     &body,
+		Position: position,
+	}
+
+	discussion, res, err := a.client.CreateMergeRequestDiscussion(a.projectInfo.ProjectId, a.mergeId(r), &opt)
+
+	if err != nil {
+		handleError(w, err, "Could not create suggestion", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not create suggestion", res.StatusCode)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := SuggestionCommentResponse{
+		SuccessResponse: SuccessResponse{Message: "Suggestion created successfully"},
+		Discussion:      discussion,
+	}
+	if len(discussion.Notes) > 0 {
+		response.Comment = discussion.Notes[0]
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+func (a suggestionCommentService) postSuggestionDraftNote(w http.ResponseWriter, r *http.Request, body string, position *gitlab.PositionOptions) {
+	opt := gitlab.CreateDraftNoteOptions{
+		Note:     &body,
+		Position: position,
+	}
+
+	draftNote, res, err := a.client.CreateDraftNote(a.projectInfo.ProjectId, a.mergeId(r), &opt)
+
+	if err != nil {
+		handleError(w, err, "Could not create draft suggestion", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not create draft suggestion", res.StatusCode)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := SuggestionCommentResponse{
+		SuccessResponse: SuccessResponse{Message: "Draft suggestion created successfully"},
+		IsDraft:         true,
+		DraftNote:       draftNote,
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/*
+buildSuggestionBody fences the replacement text as a suggestion. Gitlab anchors a suggestion on the line the
+comment is left on, so the :-N+M suffix counts the lines of the range above and below that line. The fence is
+made longer than any run of backticks in the suggestion so code blocks inside it do not end it early.
+*/
+func buildSuggestionBody(payload *PostSuggestionRequest) (string, error) {
+	if payload.FileName == "" {
+		return "", errors.New("suggestions must be left on a file")
+	}
+
+	if payload.Type != "" && payload.Type != positionTypeText {
+		return "", fmt.Errorf("suggestions must be left on a line, not on a %s position", payload.Type)
+	}
+
+	if payload.NewLine == nil {
+		return "", errors.New("suggestions can only replace lines in the new version of the file")
+	}
+
+	anchor := *payload.NewLine
+	above, below := 0, 0
+	if payload.LineRange != nil {
+		if payload.LineRange.StartRange == nil || payload.LineRange.EndRange == nil {
+			return "", errors.New("line range needs a start and an end")
+		}
+		start, end := payload.LineRange.StartRange.NewLine, payload.LineRange.EndRange.NewLine
+		if start == 0 || end == 0 {
+			return "", errors.New("suggestions can only replace lines in the new version of the file")
+		}
+		if start > anchor || end < anchor {
+			return "", fmt.Errorf("line %d is outside of the range %d-%d", anchor, start, end)
+		}
+		above, below = anchor-start, end-anchor
+	}
+
+	fence := strings.Repeat("`", longestBacktickRun(payload.Suggestion)+1)
+	if len(fence) < 3 {
+		fence = "```"
+	}
+
+	var body strings.Builder
+	if payload.Comment != "" {
+		body.WriteString(payload.Comment)
+		body.WriteString("\n\n")
+	}
+	fmt.Fprintf(&body, "%ssuggestion:-%d+%d\n", fence, above, below)
+	/* An empty suggestion deletes the lines, a single newline replaces them with a blank line */
+	if payload.Suggestion != "" {
+		body.WriteString(strings.TrimSuffix(payload.Suggestion, "\n"))
+		body.WriteString("\n")
+	}
+	body.WriteString(fence)
+
+	return body.String(), nil
+}
+
+func longestBacktickRun(s string) int {
+	longest, current := 0, 0
+	for _, c := range s {
+		if c != '`' {
+			current = 0
+			continue
+		}
+		current++
+		if current > longest {
+			longest = current
+		}
+	}
+	return longest
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeSuggestionCommentClient struct {
+	fakeCommentClient
+	position *gitlab.PositionOptions
+}
+
+func (f fakeSuggestionCommentClient) CreateMergeRequestDiscussion(pid interface{}, mergeRequest int, opt *gitlab.CreateMergeRequestDiscussionOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Discussion, *gitlab.Response, error) {
+	if f.position != nil {
+		*f.position = *opt.Position
+	}
+	return f.fakeCommentClient.CreateMergeRequestDiscussion(pid, mergeRequest, opt, options...)
+}
+
+func (f fakeSuggestionCommentClient) CreateDraftNote(pid interface{}, mergeRequest int, opt *gitlab.CreateDraftNoteOptions, options ...gitlab.RequestOptionFunc) (*gitlab.DraftNote, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.position != nil {
+		*f.position = *opt.Position
+	}
+	return &gitlab.DraftNote{}, resp, err
+}
+
+func TestPostSuggestion(t *testing.T) {
+	var testSuggestionRequest = PostSuggestionRequest{
+		Suggestion:   "fixed()",
+		PositionData: PositionData{FileName: "main.go", NewLine: gitlab.Ptr(10)},
+	}
+
+	t.Run("Creates a suggestion comment on a text position", func(t *testing.T) {
+		var position gitlab.PositionOptions
+		request := makeRequest(t, http.MethodPost, "/mr/comment/suggestion", testSuggestionRequest)
+		svc := middleware(
+			suggestionCommentService{testProjectData, fakeSuggestionCommentClient{position: &position}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostSuggestionRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getSuccessData(t, svc, request)
+		assert(t, data.Message, "Suggestion created successfully")
+		assert(t, *position.PositionType, positionTypeText)
+		assert(t, *position.NewPath, "main.go")
+		assert(t, *position.OldPath, "main.go")
+		assert(t, *position.NewLine, 10)
+	})
+	t.Run("Creates a draft suggestion", func(t *testing.T) {
+		draftRequest := testSuggestionRequest
+		draftRequest.IsDraft = true
+		var position gitlab.PositionOptions
+		request := makeRequest(t, http.MethodPost, "/mr/comment/suggestion", draftRequest)
+		svc := middleware(
+			suggestionCommentService{testProjectData, fakeSuggestionCommentClient{position: &position}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostSuggestionRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data := getSuccessData(t, svc, request)
+		assert(t, data.Message, "Draft suggestion created successfully")
+		assert(t, *position.PositionType, positionTypeText)
+		assert(t, *position.NewLine, 10)
+	})
+	t.Run("Rejects suggestions on a whole file", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment/suggestion", PostSuggestionRequest{
+			Suggestion:   "fixed()",
+			PositionData: PositionData{FileName: "main.go", Type: positionTypeFile},
+		})
+		svc := middleware(
+			suggestionCommentService{testProjectData, fakeSuggestionCommentClient{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostSuggestionRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, status := getFailData(t, svc, request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Message, "Invalid suggestion")
+		assert(t, data.Details, "suggestions must be left on a line, not on a file position")
+	})
+	t.Run("Rejects suggestions on removed lines", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment/suggestion", PostSuggestionRequest{
+			Suggestion:   "fixed()",
+			PositionData: PositionData{FileName: "main.go", OldLine: gitlab.Ptr(10)},
+		})
+		svc := middleware(
+			suggestionCommentService{testProjectData, fakeSuggestionCommentClient{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostSuggestionRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, status := getFailData(t, svc, request)
+		assert(t, status, http.StatusBadRequest)
+		assert(t, data.Message, "Invalid suggestion")
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment/suggestion", testSuggestionRequest)
+		svc := middleware(
+			suggestionCommentService{testProjectData, fakeSuggestionCommentClient{fakeCommentClient: fakeCommentClient{testBase{errFromGitlab: true}}}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostSuggestionRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkErrorFromGitlab(t, data, "Could not create suggestion")
+	})
+	t.Run("Handles non-200s from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/comment/suggestion", testSuggestionRequest)
+		svc := middleware(
+			suggestionCommentService{testProjectData, fakeSuggestionCommentClient{fakeCommentClient: fakeCommentClient{testBase{status: http.StatusSeeOther}}}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[PostSuggestionRequest]}),
+			withMethodCheck(http.MethodPost),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkNon200(t, data, "Could not create suggestion", "/mr/comment/suggestion")
+	})
+}
+
+func TestBuildSuggestionBody(t *testing.T) {
+	t.Run("Replaces a single line", func(t *testing.T) {
+		body, _ := buildSuggestionBody(&PostSuggestionRequest{
+			Suggestion:   "fixed()\n",
+			PositionData: PositionData{FileName: "main.go", NewLine: gitlab.Ptr(10)},
+		})
+		assert(t, body, "```suggestion:-0+0\nfixed()\n```")
+	})
+	t.Run("Counts the lines of a range around the commented line", func(t *testing.T) {
+		body, _ := buildSuggestionBody(&PostSuggestionRequest{
+			Comment:    "How about:",
+			Suggestion: "a\nb",
+			PositionData: PositionData{
+				FileName: "main.go",
+				NewLine:  gitlab.Ptr(12),
+				LineRange: &LineRange{
+					StartRange: &LinePosition{NewLine: 10},
+					EndRange:   &LinePosition{NewLine: 13},
+				},
+			},
+		})
+		assert(t, body, "How about:\n\n```suggestion:-2+1\na\nb\n```")
+	})
+	t.Run("Deletes lines with an empty suggestion", func(t *testing.T) {
+		body, _ := buildSuggestionBody(&PostSuggestionRequest{
+			PositionData: PositionData{FileName: "main.go", NewLine: gitlab.Ptr(10)},
+		})
+		assert(t, body, "```suggestion:-0+0\n```")
+	})
+	t.Run("Uses a longer fence when the suggestion contains backticks", func(t *testing.T) {
+		body, _ := buildSuggestionBody(&PostSuggestionRequest{
+			Suggestion:   "```go\nx\n```",
+			PositionData: PositionData{FileName: "README.md", NewLine: gitlab.Ptr(1)},
+		})
+		assert(t, body, "````suggestion:-0+0\n```go\nx\n```\n````")
+	})
+	t.Run("Rejects a commented line outside the range", func(t *testing.T) {
+		_, err := buildSuggestionBody(&PostSuggestionRequest{
+			PositionData: PositionData{
+				FileName:  "main.go",
+				NewLine:   gitlab.Ptr(20),
+				LineRange: &LineRange{StartRange: &LinePosition{NewLine: 10}, EndRange: &LinePosition{NewLine: 13}},
+			},
+		})
+		assert(t, err != nil, true)
+	})
+}
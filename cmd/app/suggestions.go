@@ -0,0 +1,272 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type SuggestionManager interface {
+	ListMergeRequestDiscussionSuggestions(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiscussionsOptions, options ...gitlab.RequestOptionFunc) ([]*SuggestionDiscussion, *gitlab.Response, error)
+	ApplySuggestion(suggestion int, opt *ApplySuggestionOptions, options ...gitlab.RequestOptionFunc) (*Suggestion, *gitlab.Response, error)
+	ApplyMultipleSuggestions(opt *ApplyMultipleSuggestionsOptions, options ...gitlab.RequestOptionFunc) ([]*Suggestion, *gitlab.Response, error)
+	MergeRequestGetter
+	GetBranch(pid interface{}, branch string, options ...gitlab.RequestOptionFunc) (*gitlab.Branch, *gitlab.Response, error)
+}
+
+type suggestionsService struct {
+	data
+	client SuggestionManager
+}
+
+/*
+ParsedSuggestion is a ```suggestion block read out of a note. LinesAbove and LinesBelow come from the optional
+:-N+M suffix and say how many lines around the commented line the suggestion replaces. The ID is only set when
+Gitlab returned the suggestion alongside the note, and is needed to apply it.
+*/
+type ParsedSuggestion struct {
+	ID           int                  `json:"id,omitempty"`
+	DiscussionId string               `json:"discussion_id"`
+	NoteId       int                  `json:"note_id"`
+	Author       string               `json:"author"`
+	Position     *gitlab.NotePosition `json:"position,omitempty"`
+	LinesAbove   int                  `json:"lines_above"`
+	LinesBelow   int                  `json:"lines_below"`
+	Content      string               `json:"content"`
+	Appliable    bool                 `json:"appliable"`
+	Applied      bool                 `json:"applied"`
+}
+
+type ListSuggestionsResponse struct {
+	SuccessResponse
+	Suggestions []ParsedSuggestion `json:"suggestions"`
+}
+
+type ApplySuggestionsRequest struct {
+	Ids           []int  `json:"ids" validate:"required,min=1"`
+	CommitMessage string `json:"commit_message"`
+}
+
+/*
+ApplySuggestionsResponse returns the commit at the head of the source branch once the suggestions are applied.
+When the branch still points at the commit from before, Gitlab has not updated it yet: CommitPending is set and
+Commit is left empty rather than reporting the old head as the suggestion commit.
+*/
+type ApplySuggestionsResponse struct {
+	SuccessResponse
+	Suggestions   []*Suggestion  `json:"suggestions"`
+	Commit        *gitlab.Commit `json:"commit"`
+	CommitPending bool           `json:"commit_pending"`
+}
+
+/* suggestionsHandler lists the suggestions left in review comments, or applies some of them to the source branch */
+func (a suggestionsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		a.listSuggestions(w, r)
+	case http.MethodPost:
+		a.applySuggestions(w, r)
+	}
+}
+
+/* listSuggestions reads every suggestion out of the merge request's discussions */
+func (a suggestionsService) listSuggestions(w http.ResponseWriter, r *http.Request) {
+	var discussions []*SuggestionDiscussion
+	opt := &gitlab.ListMergeRequestDiscussionsOptions{Page: 1, PerPage: discussionsPerPage}
+	for {
+		page, res, err := a.client.ListMergeRequestDiscussionSuggestions(a.projectInfo.ProjectId, a.mergeId(r), opt)
+		if err != nil {
+			handleError(w, err, "Could not list suggestions", http.StatusInternalServerError)
+			return
+		}
+
+		if res.StatusCode >= 300 {
+			handleError(w, GenericError{r.URL.Path}, "Could not list suggestions", res.StatusCode)
+			return
+		}
+
+		discussions = append(discussions, page...)
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	suggestions := []ParsedSuggestion{}
+	for _, discussion := range discussions {
+		for _, note := range discussion.Notes {
+			if note.System {
+				continue
+			}
+			suggestions = append(suggestions, suggestionsFromNote(discussion.ID, note)...)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response := ListSuggestionsResponse{
+		SuccessResponse: SuccessResponse{Message: "Suggestions retrieved"},
+		Suggestions:     suggestions,
+	}
+
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/* applySuggestions applies one suggestion, or several in a single commit, and returns that commit */
+func (a suggestionsService) applySuggestions(w http.ResponseWriter, r *http.Request) {
+	payload := r.Context().Value(payload("payload")).(*ApplySuggestionsRequest)
+
+	/* Gitlab does not return the commit it made, so remember the head from before to find it afterwards */
+	mr, res, err := a.client.GetMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.GetMergeRequestsOptions{})
+	if err != nil {
+		handleError(w, err, "Could not get merge request", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not get merge request", res.StatusCode)
+		return
+	}
+
+	var commitMessage *string
+	if payload.CommitMessage != "" {
+		commitMessage = gitlab.Ptr(payload.CommitMessage)
+	}
+
+	var applied []*Suggestion
+	if len(payload.Ids) == 1 {
+		var suggestion *Suggestion
+		suggestion, res, err = a.client.ApplySuggestion(payload.Ids[0], &ApplySuggestionOptions{CommitMessage: commitMessage})
+		applied = []*Suggestion{suggestion}
+	} else {
+		applied, res, err = a.client.ApplyMultipleSuggestions(&ApplyMultipleSuggestionsOptions{IDs: payload.Ids, CommitMessage: commitMessage})
+	}
+
+	if err != nil {
+		handleError(w, err, "Could not apply suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Could not apply suggestions", res.StatusCode)
+		return
+	}
+
+	/*
+		The merge request's commit list is refreshed asynchronously after a push, so the commit is read from the
+		source branch, which may live in a fork
+	*/
+	sourceProject := interface{}(a.projectInfo.ProjectId)
+	if mr.SourceProjectID != 0 {
+		sourceProject = mr.SourceProjectID
+	}
+
+	branch, res, err := a.client.GetBranch(sourceProject, mr.SourceBranch)
+	if err != nil {
+		handleError(w, err, "Applied suggestions but could not get the commit", http.StatusInternalServerError)
+		return
+	}
+
+	if res.StatusCode >= 300 {
+		handleError(w, GenericError{r.URL.Path}, "Applied suggestions but could not get the commit", res.StatusCode)
+		return
+	}
+
+	if branch.Commit == nil {
+		handleError(w, errors.New("source branch has no commit"), "Applied suggestions but could not get the commit", http.StatusInternalServerError)
+		return
+	}
+
+	response := ApplySuggestionsResponse{
+		SuccessResponse: SuccessResponse{Message: "Suggestions applied"},
+		Suggestions:     applied,
+		Commit:          branch.Commit,
+	}
+
+	if branch.Commit.ID == mr.SHA {
+		response.Message = "Suggestions applied, Gitlab has not updated the source branch yet"
+		response.Commit = nil
+		response.CommitPending = true
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		handleError(w, err, "Could not encode response", http.StatusInternalServerError)
+	}
+}
+
+/* suggestionsFromNote parses the note's suggestion blocks, matching them in order to the suggestions from Gitlab */
+func suggestionsFromNote(discussionId string, note *SuggestionNote) []ParsedSuggestion {
+	blocks := parseSuggestionBlocks(note.Body)
+
+	author := ""
+	if note.Author != nil {
+		author = note.Author.Username
+	}
+
+	suggestions := make([]ParsedSuggestion, 0, len(blocks))
+	for i, block := range blocks {
+		block.DiscussionId = discussionId
+		block.NoteId = note.ID
+		block.Author = author
+		block.Position = note.Position
+		if i < len(note.Suggestions) {
+			block.ID = note.Suggestions[i].ID
+			block.Appliable = note.Suggestions[i].Appliable
+			block.Applied = note.Suggestions[i].Applied
+		}
+		suggestions = append(suggestions, block)
+	}
+
+	return suggestions
+}
+
+var suggestionFenceRegex = regexp.MustCompile("^(```+)suggestion(?::-(\\d+)\\+(\\d+))?\\s*$")
+
+/* parseSuggestionBlocks finds the ```suggestion:-N+M fenced blocks in a note body */
+func parseSuggestionBlocks(body string) []ParsedSuggestion {
+	var blocks []ParsedSuggestion
+	var current *ParsedSuggestion
+	var fence string
+	var lines []string
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if current == nil {
+			match := suggestionFenceRegex.FindStringSubmatch(strings.TrimSpace(line))
+			if match == nil {
+				continue
+			}
+			fence = match[1]
+			current = &ParsedSuggestion{}
+			current.LinesAbove, _ = strconv.Atoi(match[2])
+			current.LinesBelow, _ = strconv.Atoi(match[3])
+			lines = nil
+			continue
+		}
+
+		if strings.TrimSpace(line) == fence {
+			current.Content = strings.Join(lines, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return blocks
+}
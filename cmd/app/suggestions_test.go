@@ -0,0 +1,200 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+type fakeSuggestionManager struct {
+	testBase
+	branchNotUpdated bool
+}
+
+func (f fakeSuggestionManager) ListMergeRequestDiscussionSuggestions(pid interface{}, mergeRequest int, opt *gitlab.ListMergeRequestDiscussionsOptions, options ...gitlab.RequestOptionFunc) ([]*SuggestionDiscussion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*SuggestionDiscussion{
+		{
+			ID: "abc",
+			Notes: []*SuggestionNote{
+				{
+					ID:          1,
+					Body:        "Try this:\n```suggestion:-1+2\nfoo()\nbar()\n```\nand\n```suggestion\nbaz()\n```",
+					Author:      &gitlab.BasicUser{Username: "hcramer"},
+					Suggestions: []*Suggestion{{ID: 10, Appliable: true}, {ID: 11, Applied: true}},
+				},
+				{ID: 2, Body: "added 1 commit", System: true},
+			},
+		},
+	}, resp, err
+}
+
+func (f fakeSuggestionManager) ApplySuggestion(suggestion int, opt *ApplySuggestionOptions, options ...gitlab.RequestOptionFunc) (*Suggestion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Suggestion{ID: suggestion, Applied: true}, resp, err
+}
+
+func (f fakeSuggestionManager) ApplyMultipleSuggestions(opt *ApplyMultipleSuggestionsOptions, options ...gitlab.RequestOptionFunc) ([]*Suggestion, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var applied []*Suggestion
+	for _, id := range opt.IDs {
+		applied = append(applied, &Suggestion{ID: id, Applied: true})
+	}
+	return applied, resp, err
+}
+
+func (f fakeSuggestionManager) GetMergeRequest(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestsOptions, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequest, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gitlab.MergeRequest{SHA: "old123", SourceBranch: "feature"}, resp, err
+}
+
+/* The commit list is refreshed asynchronously, so right after applying it still shows the head from before */
+func (f fakeSuggestionManager) GetMergeRequestCommits(pid interface{}, mergeRequest int, opt *gitlab.GetMergeRequestCommitsOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Commit, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*gitlab.Commit{{ID: "old123"}}, resp, err
+}
+
+func (f fakeSuggestionManager) GetBranch(pid interface{}, branch string, options ...gitlab.RequestOptionFunc) (*gitlab.Branch, *gitlab.Response, error) {
+	resp, err := f.handleGitlabError()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if f.branchNotUpdated {
+		return &gitlab.Branch{Name: branch, Commit: &gitlab.Commit{ID: "old123"}}, resp, err
+	}
+	return &gitlab.Branch{Name: branch, Commit: &gitlab.Commit{ID: "new456"}}, resp, err
+}
+
+func TestSuggestionsHandler(t *testing.T) {
+	t.Run("Lists suggestions from discussion notes", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/suggestions", nil)
+		svc := middleware(
+			suggestionsService{testProjectData, fakeSuggestionManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ApplySuggestionsRequest]}),
+			withMethodCheck(http.MethodGet, http.MethodPost),
+		)
+		res := httptest.NewRecorder()
+		svc.ServeHTTP(res, request)
+
+		var data ListSuggestionsResponse
+		err := json.Unmarshal(res.Body.Bytes(), &data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, data.Message, "Suggestions retrieved")
+		assert(t, len(data.Suggestions), 2)
+		assert(t, data.Suggestions[0].ID, 10)
+		assert(t, data.Suggestions[0].DiscussionId, "abc")
+		assert(t, data.Suggestions[0].Author, "hcramer")
+		assert(t, data.Suggestions[0].Content, "foo()\nbar()")
+		assert(t, data.Suggestions[0].LinesAbove, 1)
+		assert(t, data.Suggestions[0].LinesBelow, 2)
+		assert(t, data.Suggestions[1].Applied, true)
+	})
+	t.Run("Applies a batch of suggestions and returns the commit", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/suggestions", ApplySuggestionsRequest{Ids: []int{10, 11}})
+		svc := middleware(
+			suggestionsService{testProjectData, fakeSuggestionManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ApplySuggestionsRequest]}),
+			withMethodCheck(http.MethodGet, http.MethodPost),
+		)
+		res := httptest.NewRecorder()
+		svc.ServeHTTP(res, request)
+
+		var data ApplySuggestionsResponse
+		err := json.Unmarshal(res.Body.Bytes(), &data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, data.Message, "Suggestions applied")
+		assert(t, len(data.Suggestions), 2)
+		assert(t, data.Commit.ID, "new456")
+		assert(t, data.CommitPending, false)
+	})
+	t.Run("Reports the commit as pending while the source branch has the old head", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/suggestions", ApplySuggestionsRequest{Ids: []int{10}})
+		svc := middleware(
+			suggestionsService{testProjectData, fakeSuggestionManager{branchNotUpdated: true}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ApplySuggestionsRequest]}),
+			withMethodCheck(http.MethodGet, http.MethodPost),
+		)
+		data := decodeResponse[ApplySuggestionsResponse](t, svc, request)
+		assert(t, data.CommitPending, true)
+		assert(t, data.Commit == nil, true)
+		assert(t, data.Message, "Suggestions applied, Gitlab has not updated the source branch yet")
+	})
+	t.Run("Requires at least one suggestion", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/suggestions", ApplySuggestionsRequest{Ids: []int{}})
+		svc := middleware(
+			suggestionsService{testProjectData, fakeSuggestionManager{}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ApplySuggestionsRequest]}),
+			withMethodCheck(http.MethodGet, http.MethodPost),
+		)
+		_, status := getFailData(t, svc, request)
+		assert(t, status, http.StatusBadRequest)
+	})
+	t.Run("Handles errors from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodPost, "/mr/suggestions", ApplySuggestionsRequest{Ids: []int{10}})
+		svc := middleware(
+			suggestionsService{testProjectData, fakeSuggestionManager{testBase: testBase{errFromGitlab: true}}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ApplySuggestionsRequest]}),
+			withMethodCheck(http.MethodGet, http.MethodPost),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkErrorFromGitlab(t, data, "Could not get merge request")
+	})
+	t.Run("Handles non-200s from Gitlab client", func(t *testing.T) {
+		request := makeRequest(t, http.MethodGet, "/mr/suggestions", nil)
+		svc := middleware(
+			suggestionsService{testProjectData, fakeSuggestionManager{testBase: testBase{status: http.StatusSeeOther}}},
+			withMr(testProjectData, fakeMergeRequestLister{}),
+			withPayloadValidation(methodToPayload{http.MethodPost: newPayload[ApplySuggestionsRequest]}),
+			withMethodCheck(http.MethodGet, http.MethodPost),
+		)
+		data, _ := getFailData(t, svc, request)
+		checkNon200(t, data, "Could not list suggestions", "/mr/suggestions")
+	})
+}
+
+func TestParseSuggestionBlocks(t *testing.T) {
+	t.Run("Keeps longer fences and ignores other code blocks", func(t *testing.T) {
+		blocks := parseSuggestionBlocks("```go\nnot a suggestion\n```\n````suggestion:-0+1\nhas ``` inside\n````")
+		assert(t, len(blocks), 1)
+		assert(t, blocks[0].Content, "has ``` inside")
+		assert(t, blocks[0].LinesBelow, 1)
+	})
+	t.Run("Parses an empty suggestion, which deletes lines", func(t *testing.T) {
+		blocks := parseSuggestionBlocks("```suggestion\n```")
+		assert(t, len(blocks), 1)
+		assert(t, blocks[0].Content, "")
+	})
+}
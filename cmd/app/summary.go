@@ -26,10 +26,10 @@ func (a summaryService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	payload := r.Context().Value(payload("payload")).(*SummaryUpdateRequest)
 
-	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.projectInfo.MergeId, &gitlab.UpdateMergeRequestOptions{
+	mr, res, err := a.client.UpdateMergeRequest(a.projectInfo.ProjectId, a.mergeId(r), &gitlab.UpdateMergeRequestOptions{
 		Description: &payload.Description,
 		Title:       &payload.Title,
-	})
+	}, withRetries())
 
 	if err != nil {
 		handleError(w, err, "Could not edit merge request summary", http.StatusInternalServerError)
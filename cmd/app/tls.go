@@ -0,0 +1,54 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+/*
+buildTLSConfig creates the TLS configuration for connections to Gitlab. Certificates from the configured CA bundle are
+trusted in addition to the system roots, and a client certificate is presented when one is configured (mTLS).
+*/
+func buildTLSConfig() (*tls.Config, error) {
+	settings := pluginOptions.ConnectionSettings
+
+	config := &tls.Config{
+		InsecureSkipVerify: settings.Insecure,
+	}
+
+	if settings.CaFile != "" {
+		pem, err := os.ReadFile(settings.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s does not contain any PEM encoded certificates", settings.CaFile)
+		}
+
+		config.RootCAs = pool
+	}
+
+	if settings.ClientCertFile != "" || settings.ClientKeyFile != "" {
+		if settings.ClientCertFile == "" || settings.ClientKeyFile == "" {
+			return nil, errors.New("client_cert_file and client_key_file must be provided together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(settings.ClientCertFile, settings.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* writeTestCertificate writes a self-signed certificate and its key to a temporary directory */
+func writeTestCertificate(t *testing.T) (certFile string, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gitlab.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	defer SetPluginOptions(PluginOptions{})
+
+	t.Run("Trusts the CA bundle and presents the client certificate", func(t *testing.T) {
+		certFile, keyFile := writeTestCertificate(t)
+		options := PluginOptions{}
+		options.ConnectionSettings.CaFile = certFile
+		options.ConnectionSettings.ClientCertFile = certFile
+		options.ConnectionSettings.ClientKeyFile = keyFile
+		SetPluginOptions(options)
+
+		config, err := buildTLSConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, config.RootCAs != nil, true)
+		assert(t, len(config.Certificates), 1)
+	})
+	t.Run("Rejects a CA bundle without certificates", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		err := os.WriteFile(path, []byte("not a certificate"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		options := PluginOptions{}
+		options.ConnectionSettings.CaFile = path
+		SetPluginOptions(options)
+
+		_, err = buildTLSConfig()
+		assert(t, strings.Contains(err.Error(), "does not contain any PEM encoded certificates"), true)
+	})
+	t.Run("Rejects a client certificate without a key", func(t *testing.T) {
+		certFile, _ := writeTestCertificate(t)
+		options := PluginOptions{}
+		options.ConnectionSettings.ClientCertFile = certFile
+		SetPluginOptions(options)
+
+		_, err := buildTLSConfig()
+		assert(t, err.Error(), "client_cert_file and client_key_file must be provided together")
+	})
+	t.Run("Rejects a missing CA bundle", func(t *testing.T) {
+		options := PluginOptions{}
+		options.ConnectionSettings.CaFile = "/does/not/exist"
+		SetPluginOptions(options)
+
+		_, err := buildTLSConfig()
+		assert(t, strings.HasPrefix(err.Error(), "could not read CA bundle"), true)
+	})
+}
@@ -0,0 +1,216 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	tokenEnvVariable    = "GITLAB_TOKEN"
+	jobTokenEnvVariable = "CI_JOB_TOKEN"
+)
+
+/* tokenSource is a single place the Gitlab token can be read from. An empty token means the source had nothing. */
+type tokenSource struct {
+	name    string
+	resolve func() (string, error)
+}
+
+/*
+resolveToken walks the token sources in priority order and returns the first token found: the GITLAB_TOKEN
+environment variable, the configured token file, `git credential fill` for the Gitlab host, and finally the
+glab CLI config. When authenticating with a job token, CI_JOB_TOKEN is checked first. Which sources were
+tried is written to the log when auth debugging is on, never the token itself.
+*/
+func resolveToken() (string, error) {
+	host, err := gitlabHost()
+	if err != nil {
+		return "", err
+	}
+
+	sources := []tokenSource{
+		{fmt.Sprintf("environment variable %s", tokenEnvVariable), func() (string, error) { return tokenFromEnv(tokenEnvVariable) }},
+		{fmt.Sprintf("token file %q", pluginOptions.AuthTokenFile), tokenFromFile},
+		{fmt.Sprintf("git credential for %s", host.Host), func() (string, error) { return tokenFromGitCredential(host) }},
+		{"glab CLI config", func() (string, error) { return tokenFromGlabConfig(host.Host) }},
+	}
+
+	if pluginOptions.AuthType == authTypeJob {
+		jobTokenSource := tokenSource{fmt.Sprintf("environment variable %s", jobTokenEnvVariable), func() (string, error) { return tokenFromEnv(jobTokenEnvVariable) }}
+		sources = append([]tokenSource{jobTokenSource}, sources...)
+	}
+
+	var report strings.Builder
+	defer func() {
+		if pluginOptions.Debug.Auth {
+			logMessage("GITLAB TOKEN RESOLUTION", report.String())
+		}
+	}()
+
+	for _, source := range sources {
+		token, err := source.resolve()
+		switch {
+		case err != nil:
+			fmt.Fprintf(&report, "%s: error (%v)\n", source.name, err)
+		case token == "":
+			fmt.Fprintf(&report, "%s: not found\n", source.name)
+		default:
+			fmt.Fprintf(&report, "%s: found token (REDACTED)\n", source.name)
+			return token, nil
+		}
+	}
+
+	return "", fmt.Errorf("no Gitlab token found, checked:\n%s", report.String())
+}
+
+func gitlabHost() (*url.URL, error) {
+	u, err := url.Parse(pluginOptions.GitlabUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Gitlab URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid Gitlab URL: %s", pluginOptions.GitlabUrl)
+	}
+	return u, nil
+}
+
+func tokenFromEnv(name string) (string, error) {
+	return strings.TrimSpace(os.Getenv(name)), nil
+}
+
+func tokenFromFile() (string, error) {
+	if pluginOptions.AuthTokenFile == "" {
+		return "", nil
+	}
+
+	b, err := os.ReadFile(pluginOptions.AuthTokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+/* tokenFromGitCredential asks git's configured credential helpers for the password stored for the Gitlab host */
+func tokenFromGitCredential(host *url.URL) (string, error) {
+	input := fmt.Sprintf("protocol=%s\nhost=%s\n\n", host.Scheme, host.Host)
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(input)
+	/* Never prompt, the server has no terminal */
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	if err != nil {
+		/* git exits non-zero when no helper has a credential and it is not allowed to prompt */
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return parseGitCredential(&stdout), nil
+}
+
+func parseGitCredential(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found && key == "password" {
+			return value
+		}
+	}
+	return ""
+}
+
+/* glabConfigPath mirrors where the glab CLI keeps its config */
+func glabConfigPath() (string, error) {
+	if dir := os.Getenv("GLAB_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "config.yml"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "glab-cli", "config.yml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "glab-cli", "config.yml"), nil
+}
+
+func tokenFromGlabConfig(host string) (string, error) {
+	path, err := glabConfigPath()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+
+	return parseGlabConfig(f, host), nil
+}
+
+/*
+parseGlabConfig reads hosts.<host>.token out of glab's YAML config. The file has a fixed, indentation-based
+layout, so we scan it line by line rather than pulling in a YAML library. Tokens glab keeps in the system
+keyring are stored as !!null and are ignored.
+*/
+func parseGlabConfig(r io.Reader, host string) string {
+	scanner := bufio.NewScanner(r)
+	inHosts, inHost := false, false
+	hostIndent := -1
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if indent == 0 {
+			inHosts = trimmed == "hosts:"
+			inHost = false
+			continue
+		}
+		if !inHosts {
+			continue
+		}
+
+		if hostIndent == -1 || indent <= hostIndent {
+			hostIndent = indent
+			inHost = strings.Trim(strings.TrimSuffix(trimmed, ":"), `"'`) == host
+			continue
+		}
+
+		if inHost {
+			key, value, found := strings.Cut(trimmed, ":")
+			if found && key == "token" {
+				value = strings.Trim(strings.TrimSpace(value), `"'`)
+				if value == "!!null" {
+					return ""
+				}
+				return value
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveToken(t *testing.T) {
+	t.Run("Prefers the environment variable", func(t *testing.T) {
+		t.Setenv(tokenEnvVariable, "env-token")
+		SetPluginOptions(PluginOptions{GitlabUrl: "https://gitlab.com"})
+		defer SetPluginOptions(PluginOptions{})
+		token, err := resolveToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, token, "env-token")
+	})
+	t.Run("Reads the token file", func(t *testing.T) {
+		t.Setenv(tokenEnvVariable, "")
+		path := filepath.Join(t.TempDir(), "token")
+		err := os.WriteFile(path, []byte("file-token\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetPluginOptions(PluginOptions{GitlabUrl: "https://gitlab.com", AuthTokenFile: path})
+		defer SetPluginOptions(PluginOptions{})
+		token, err := resolveToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert(t, token, "file-token")
+	})
+	t.Run("Reports a missing token file", func(t *testing.T) {
+		t.Setenv(tokenEnvVariable, "")
+		t.Setenv("GLAB_CONFIG_DIR", t.TempDir())
+		t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+		t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+		SetPluginOptions(PluginOptions{GitlabUrl: "https://gitlab.example.com", AuthTokenFile: "/does/not/exist"})
+		defer SetPluginOptions(PluginOptions{})
+		_, err := resolveToken()
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		assert(t, strings.Contains(err.Error(), `token file "/does/not/exist": error`), true)
+	})
+}
+
+func TestParseGitCredential(t *testing.T) {
+	output := "protocol=https\nhost=gitlab.com\nusername=oauth2\npassword=secret-token\n"
+	assert(t, parseGitCredential(strings.NewReader(output)), "secret-token")
+	assert(t, parseGitCredential(strings.NewReader("protocol=https\n")), "")
+}
+
+func TestParseGlabConfig(t *testing.T) {
+	config := `git_protocol: ssh
+hosts:
+    gitlab.com:
+        api_protocol: https
+        token: gitlab-com-token
+    gitlab.example.com:
+        token: "example-token"
+        api_host: gitlab.example.com
+    keyring.example.com:
+        token: !!null
+editor: vim
+`
+	assert(t, parseGlabConfig(strings.NewReader(config), "gitlab.com"), "gitlab-com-token")
+	assert(t, parseGlabConfig(strings.NewReader(config), "gitlab.example.com"), "example-token")
+	assert(t, parseGlabConfig(strings.NewReader(config), "keyring.example.com"), "")
+	assert(t, parseGlabConfig(strings.NewReader(config), "unknown.com"), "")
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	defaultRetryWaitMin = 500 * time.Millisecond
+	defaultRetryWaitMax = 10 * time.Second
+)
+
+/* buildProxy uses the proxy from the connection settings if there is one, otherwise HTTPS_PROXY, HTTP_PROXY and NO_PROXY */
+func buildProxy() (func(*http.Request) (*url.URL, error), error) {
+	if pluginOptions.ConnectionSettings.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyUrl, err := url.Parse(pluginOptions.ConnectionSettings.Proxy)
+	if err != nil || proxyUrl.Scheme == "" || proxyUrl.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL '%s'", pluginOptions.ConnectionSettings.Proxy)
+	}
+
+	return http.ProxyURL(proxyUrl), nil
+}
+
+func retryWaits() (time.Duration, time.Duration) {
+	min, max := defaultRetryWaitMin, defaultRetryWaitMax
+	if pluginOptions.ConnectionSettings.RetryWaitMinMs > 0 {
+		min = time.Duration(pluginOptions.ConnectionSettings.RetryWaitMinMs) * time.Millisecond
+	}
+	if pluginOptions.ConnectionSettings.RetryWaitMaxMs > 0 {
+		max = time.Duration(pluginOptions.ConnectionSettings.RetryWaitMaxMs) * time.Millisecond
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+/* Only methods that cannot change anything are retried without being opted in with withRetries */
+func isSafe(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+type retriesKey struct{}
+
+/*
+withRetries opts a request into automatic retries. Only use it for requests that set a value, such as updating
+a merge request's labels, where repeating a request that already succeeded changes nothing.
+*/
+func withRetries() gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		*req = *req.WithContext(context.WithValue(req.Context(), retriesKey{}, true))
+		return nil
+	}
+}
+
+/*
+checkRetry retries requests that failed to connect, were rate limited, or hit a server error. Only safe requests
+and those opted in with withRetries are retried, since Gitlab may have acted on any other request before failing.
+A Retry-After longer than the maximum wait gives up rather than retrying early.
+*/
+func checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	optedIn, _ := ctx.Value(retriesKey{}).(bool)
+
+	if err != nil {
+		/* The Op of the error returned by http.Client is the request's method */
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) && (optedIn || isSafe(urlErr.Op)) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if resp.Request != nil && !optedIn && !isSafe(resp.Request.Method) {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && (resp.StatusCode < 500 || resp.StatusCode == http.StatusNotImplemented) {
+		return false, nil
+	}
+
+	if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		if _, max := retryWaits(); wait > max {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+/*
+retryBackoff waits as long as Gitlab asks in its Retry-After header, and at least the configured minimum.
+Otherwise it backs off exponentially with some jitter, between the configured minimum and maximum.
+*/
+func retryBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait < min {
+				return min
+			}
+			return wait
+		}
+	}
+
+	wait := float64(min) * math.Pow(2, float64(attemptNum))
+	if wait > float64(max) {
+		wait = float64(max)
+	}
+
+	/* Jitter spreads out retries from concurrent requests, such as the emoji workers */
+	jittered := time.Duration(wait/2 + rand.Float64()*wait/2)
+	return clampDuration(jittered, min, max)
+}
+
+/* parseRetryAfter reads a Retry-After header, which is either a number of seconds or an HTTP date */
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
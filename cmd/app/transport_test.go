@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+func TestCheckRetry(t *testing.T) {
+	response := func(method string, status int) *http.Response {
+		return &http.Response{StatusCode: status, Request: &http.Request{Method: method}}
+	}
+
+	t.Run("Retries safe requests on server errors and rate limits", func(t *testing.T) {
+		for _, status := range []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable} {
+			retry, _ := checkRetry(context.Background(), response(http.MethodGet, status), nil)
+			assert(t, retry, true)
+		}
+	})
+	t.Run("Does not retry successes, client errors or 501", func(t *testing.T) {
+		for _, status := range []int{http.StatusOK, http.StatusNotFound, http.StatusNotImplemented} {
+			retry, _ := checkRetry(context.Background(), response(http.MethodGet, status), nil)
+			assert(t, retry, false)
+		}
+	})
+	t.Run("Does not retry requests that can change things", func(t *testing.T) {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+			retry, _ := checkRetry(context.Background(), response(method, http.StatusServiceUnavailable), nil)
+			assert(t, retry, false)
+		}
+		retry, _ := checkRetry(context.Background(), nil, &url.Error{Op: "Put", Err: errors.New("connection reset")})
+		assert(t, retry, false)
+	})
+	t.Run("Retries requests opted in with withRetries", func(t *testing.T) {
+		req, err := retryablehttp.NewRequest(http.MethodPut, "https://gitlab.com/api/v4/projects/1/merge_requests/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = withRetries()(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		retry, _ := checkRetry(req.Context(), response(http.MethodPut, http.StatusServiceUnavailable), nil)
+		assert(t, retry, true)
+		retry, _ = checkRetry(req.Context(), nil, &url.Error{Op: "Put", Err: errors.New("connection reset")})
+		assert(t, retry, true)
+	})
+	t.Run("Gives up when Gitlab asks to wait longer than the maximum wait", func(t *testing.T) {
+		resp := response(http.MethodGet, http.StatusTooManyRequests)
+		resp.Header = http.Header{"Retry-After": []string{"60"}}
+		retry, _ := checkRetry(context.Background(), resp, nil)
+		assert(t, retry, false)
+
+		resp.Header = http.Header{"Retry-After": []string{"5"}}
+		retry, _ = checkRetry(context.Background(), resp, nil)
+		assert(t, retry, true)
+	})
+	t.Run("Retries safe requests that failed to connect", func(t *testing.T) {
+		retry, _ := checkRetry(context.Background(), nil, &url.Error{Op: "Get", Err: errors.New("connection refused")})
+		assert(t, retry, true)
+	})
+	t.Run("Stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		retry, err := checkRetry(ctx, response(http.MethodGet, http.StatusServiceUnavailable), nil)
+		assert(t, retry, false)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	min, max := 100*time.Millisecond, 2*time.Second
+
+	t.Run("Honours Retry-After in seconds", func(t *testing.T) {
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"1"}}}
+		assert(t, retryBackoff(min, max, 0, resp), time.Second)
+	})
+	t.Run("Never waits less than Retry-After", func(t *testing.T) {
+		resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": []string{"3"}}}
+		assert(t, retryBackoff(min, max, 0, resp), 3*time.Second)
+		resp.Header.Set("Retry-After", "0")
+		assert(t, retryBackoff(min, max, 0, resp), min)
+	})
+	t.Run("Backs off exponentially within the bounds", func(t *testing.T) {
+		for attempt := 0; attempt < 10; attempt++ {
+			wait := retryBackoff(min, max, attempt, nil)
+			if wait < min || wait > max {
+				t.Errorf("Wait %s for attempt %d is outside [%s, %s]", wait, attempt, min, max)
+			}
+		}
+		if retryBackoff(min, max, 4, nil) < 800*time.Millisecond {
+			t.Errorf("Expected later attempts to wait longer")
+		}
+	})
+}
+
+func TestBuildProxy(t *testing.T) {
+	t.Run("Uses the configured proxy", func(t *testing.T) {
+		pluginOptions.ConnectionSettings.Proxy = "http://proxy.example.com:8080"
+		defer func() { pluginOptions.ConnectionSettings.Proxy = "" }()
+		proxy, err := buildProxy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "gitlab.com"}})
+		assert(t, u.String(), "http://proxy.example.com:8080")
+	})
+	t.Run("Rejects an invalid proxy", func(t *testing.T) {
+		pluginOptions.ConnectionSettings.Proxy = "not a url"
+		defer func() { pluginOptions.ConnectionSettings.Proxy = "" }()
+		_, err := buildProxy()
+		assert(t, err != nil, true)
+	})
+}
@@ -19,6 +19,7 @@ local M = {
   start_line = nil,
   end_line = nil,
   draft_popup = nil,
+  internal_popup = nil,
   comment_popup = nil,
 }
 
@@ -35,21 +36,17 @@ local confirm_create_comment = function(text, visual_range, unlinked, discussion
   end
 
   local is_draft = M.draft_popup and u.string_to_bool(u.get_buffer_text(M.draft_popup.bufnr))
-
-  -- Creating a normal reply to a discussion
-  if discussion_id ~= nil and not is_draft then
-    local body = { discussion_id = discussion_id, reply = text, draft = is_draft }
-    job.run_job("/mr/reply", "POST", body, function()
-      u.notify("Sent reply!", vim.log.levels.INFO)
-      discussions.rebuild_view(unlinked)
-    end)
-    return
-  end
-
-  -- Creating a draft reply, in response to a discussion ID
-  if discussion_id ~= nil and is_draft then
-    local body = { comment = text, discussion_id = discussion_id }
-    job.run_job("/mr/draft_notes/", "POST", body, function()
+  local is_internal = M.internal_popup and u.string_to_bool(u.get_buffer_text(M.internal_popup.bufnr)) or false
+
+  -- Creating a reply to a discussion, either sent right away or saved as a draft
+  if discussion_id ~= nil then
+    local body = { discussion_id = discussion_id, reply = text, is_draft = is_draft, internal = is_internal }
+    job.run_job("/mr/reply", "POST", body, function(data)
+      if not data.is_draft then
+        u.notify("Sent reply!", vim.log.levels.INFO)
+        discussions.rebuild_view(unlinked)
+        return
+      end
       u.notify("Draft reply created!", vim.log.levels.INFO)
       draft_notes.load_draft_notes(function()
         discussions.rebuild_view(unlinked)
@@ -60,7 +57,7 @@ local confirm_create_comment = function(text, visual_range, unlinked, discussion
 
   -- Creating a note (unlinked comment)
   if unlinked and discussion_id == nil then
-    local body = { comment = text }
+    local body = { comment = text, internal = is_internal }
     local endpoint = is_draft and "/mr/draft_notes/" or "/mr/comment"
     job.run_job(endpoint, "POST", body, function()
       u.notify(is_draft and "Draft note created!" or "Note created!", vim.log.levels.INFO)
@@ -102,7 +99,7 @@ local confirm_create_comment = function(text, visual_range, unlinked, discussion
   }
 
   -- Creating a new comment (linked to specific changes)
-  local body = u.merge({ type = "text", comment = text }, position_data)
+  local body = u.merge({ type = "text", comment = text, internal = is_internal }, position_data)
   local endpoint = is_draft and "/mr/draft_notes/" or "/mr/comment"
   job.run_job(endpoint, "POST", body, function()
     u.notify(is_draft and "Draft comment created!" or "Comment created!", vim.log.levels.INFO)
@@ -177,11 +174,15 @@ M.create_comment_layout = function(opts)
   M.current_win = vim.api.nvim_get_current_win()
   M.comment_popup = Popup(popup.create_popup_state(title, settings))
   M.draft_popup = Popup(popup.create_box_popup_state("Draft", false, settings))
+  M.internal_popup = Popup(popup.create_box_popup_state("Internal", false, settings))
   M.start_line, M.end_line = u.get_visual_selection_boundaries()
 
   local internal_layout = Layout.Box({
     Layout.Box(M.comment_popup, { grow = 1 }),
-    Layout.Box(M.draft_popup, { size = 3 }),
+    Layout.Box({
+      Layout.Box(M.draft_popup, { grow = 1 }),
+      Layout.Box(M.internal_popup, { grow = 1 }),
+    }, { dir = "row", size = 3 }),
   }, { dir = "col" })
 
   local layout = Layout({
@@ -193,7 +194,7 @@ M.create_comment_layout = function(opts)
     },
   }, internal_layout)
 
-  popup.set_cycle_popups_keymaps({ M.comment_popup, M.draft_popup })
+  popup.set_cycle_popups_keymaps({ M.comment_popup, M.draft_popup, M.internal_popup })
   popup.set_up_autocommands(M.comment_popup, layout, M.current_win)
 
   local range = opts.ranged and { start_line = M.start_line, end_line = M.end_line } or nil
@@ -206,6 +207,13 @@ M.create_comment_layout = function(opts)
     vim.api.nvim_set_current_win(M.current_win)
   end, miscellaneous.toggle_bool, popup.non_editable_popup_opts)
 
+  ---Keybinding for focus on internal section
+  popup.set_popup_keymaps(M.internal_popup, function()
+    local text = u.get_buffer_text(M.comment_popup.bufnr)
+    confirm_create_comment(text, range, unlinked, opts.discussion_id)
+    vim.api.nvim_set_current_win(M.current_win)
+  end, miscellaneous.toggle_bool, popup.non_editable_popup_opts)
+
   ---Keybinding for focus on text section
   popup.set_popup_keymaps(M.comment_popup, function(text)
     confirm_create_comment(text, range, unlinked, opts.discussion_id)
@@ -215,6 +223,7 @@ M.create_comment_layout = function(opts)
   vim.schedule(function()
     local draft_mode = state.settings.discussion_tree.draft_mode
     vim.api.nvim_buf_set_lines(M.draft_popup.bufnr, 0, -1, false, { u.bool_to_string(draft_mode) })
+    vim.api.nvim_buf_set_lines(M.internal_popup.bufnr, 0, -1, false, { u.bool_to_string(false) })
   end)
 
   return layout
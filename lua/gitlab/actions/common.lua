@@ -218,9 +218,9 @@ M.get_line_number = function(id)
     return nil, true
   end
 
-  local first_note = indicators_common.get_first_note(d_or_n)
   local is_new_sha = indicators_common.is_new_sha(d_or_n)
-  return ((is_new_sha and first_note.position.new_line or first_note.position.old_line) or 1), is_new_sha
+  local old_line, new_line = indicators_common.get_current_lines(d_or_n)
+  return ((is_new_sha and new_line or old_line) or 1), is_new_sha
 end
 
 ---Return the start and end line numbers for the note range. The range is calculated from the line
@@ -64,6 +64,7 @@ M.load_discussions = function(callback)
     state.DISCUSSION_DATA.discussions = u.ensure_table(data.discussions)
     state.DISCUSSION_DATA.unlinked_discussions = u.ensure_table(data.unlinked_discussions)
     state.DISCUSSION_DATA.emojis = u.ensure_table(data.emojis)
+    state.DISCUSSION_DATA.positions = u.ensure_table(data.positions)
     if callback ~= nil then
       callback()
     end
@@ -795,14 +796,16 @@ M.toggle_draft_mode = function()
   state.settings.discussion_tree.draft_mode = not state.settings.discussion_tree.draft_mode
 end
 
----Toggle between sorting by "original comment" (oldest at the top) or "latest reply" (newest at the
----top).
+---Cycle between sorting by "latest reply" (newest at the top), "original comment" (oldest at the
+---top) and "file line" (by file, then by line).
 M.toggle_sort_method = function()
-  if state.settings.discussion_tree.sort_by == "original_comment" then
-    state.settings.discussion_tree.sort_by = "latest_reply"
-  else
-    state.settings.discussion_tree.sort_by = "original_comment"
-  end
+  ---@type table<DiscussionSortBy, DiscussionSortBy>
+  local next_sort_by = {
+    latest_reply = "original_comment",
+    original_comment = "file_line",
+    file_line = "latest_reply",
+  }
+  state.settings.discussion_tree.sort_by = next_sort_by[state.settings.discussion_tree.sort_by] or "latest_reply"
   winbar.update_winbar()
   M.rebuild_view(false, true)
 end
@@ -287,6 +287,9 @@ local function build_note_body(note, resolve_info)
   end
 
   local noteHeader = common.build_note_header(note) .. " " .. symbol
+  if note.internal then
+    noteHeader = noteHeader .. " " .. state.settings.discussion_tree.internal
+  end
 
   return noteHeader, text_nodes
 end
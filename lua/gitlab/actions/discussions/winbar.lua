@@ -210,7 +210,10 @@ end
 ---Returns a string for the winbar indicating the sort method
 ---@return string
 M.get_sort_method = function()
-  local sort_method = state.settings.discussion_tree.sort_by == "original_comment" and "↓ by thread" or "↑ by reply"
+  local sort_by = state.settings.discussion_tree.sort_by
+  local sort_method = sort_by == "original_comment" and "↓ by thread"
+    or sort_by == "file_line" and "↓ by file"
+    or "↑ by reply"
   return "%#GitlabSortMethod#" .. sort_method .. "%#Comment#"
 end
 
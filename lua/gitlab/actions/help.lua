@@ -21,10 +21,11 @@ M.open = function()
   table.insert(
     help_content_lines,
     string.format(
-      "%s = draft; %s = unlinked comment; %s = resolved",
+      "%s = draft; %s = unlinked comment; %s = resolved; %s = internal",
       state.settings.discussion_tree.draft,
       state.settings.discussion_tree.unlinked,
-      state.settings.discussion_tree.resolved
+      state.settings.discussion_tree.resolved,
+      state.settings.discussion_tree.internal
     )
   )
 
@@ -30,12 +30,17 @@ M.merge = function(opts)
   end
 
   if merge_body.squash then
-    local squash_message_popup = create_squash_message_popup()
-    popup.set_up_autocommands(squash_message_popup, nil, vim.api.nvim_get_current_win())
-    squash_message_popup:mount()
-    popup.set_popup_keymaps(squash_message_popup, function(text)
-      M.confirm_merge(merge_body, text)
-    end, nil, popup.editable_popup_opts)
+    local current_win = vim.api.nvim_get_current_win()
+    local plan_body = { squash = true, delete_branch = merge_body.delete_branch, dry_run = true }
+    job.run_job("/mr/merge", "POST", plan_body, function(data)
+      local squash_message_popup = create_squash_message_popup()
+      popup.set_up_autocommands(squash_message_popup, nil, current_win)
+      squash_message_popup:mount()
+      vim.api.nvim_buf_set_lines(squash_message_popup.bufnr, 0, -1, false, vim.split(data.squash_message, "\n"))
+      popup.set_popup_keymaps(squash_message_popup, function(text)
+        M.confirm_merge(merge_body, text)
+      end, nil, popup.editable_popup_opts)
+    end)
   else
     M.confirm_merge(merge_body)
   end
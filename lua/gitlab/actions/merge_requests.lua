@@ -50,12 +50,12 @@ M.choose_merge_request = function(opts)
       end
 
       vim.schedule(function()
-        state.chosen_mr_iid = choice.iid
-        require("gitlab.server").restart(function()
-          if opts.open_reviewer then
-            require("gitlab").review()
-          end
-        end)
+        state.mr_iid = choice.iid
+        state.mr_branch = choice.source_branch
+        state.clear_data()
+        if opts.open_reviewer then
+          require("gitlab").review()
+        end
       end)
     end)
   end)
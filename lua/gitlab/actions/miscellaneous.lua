@@ -34,6 +34,26 @@ M.attach_file = function()
   end)
 end
 
+---Export the review of the MR as Markdown (the default) or JSON. The report is written to `opts.path`
+---when given, otherwise it is opened in a new buffer.
+---@param opts { format: "markdown"|"json"|nil, path: string|nil }|nil
+M.export = function(opts)
+  opts = opts or {}
+  local format = opts.format or "markdown"
+  job.run_job("/mr/export", "POST", { format = format }, function(data)
+    local content = format == "json" and vim.json.encode(data.export) or data.markdown
+    local lines = vim.split(content, "\n", { plain = true })
+    if opts.path then
+      vim.fn.writefile(lines, opts.path)
+      u.notify("Exported review to " .. opts.path, vim.log.levels.INFO)
+      return
+    end
+    vim.cmd.enew()
+    vim.api.nvim_buf_set_lines(0, 0, -1, false, lines)
+    vim.bo.filetype = format
+  end)
+end
+
 ---Toggle the value in a "Boolean buffer"
 M.toggle_bool = function()
   local bufnr = vim.api.nvim_get_current_buf()
@@ -2,6 +2,7 @@
 
 ---@alias BorderEnum "rounded" | "single" | "double" | "solid"
 ---@alias SeverityEnum "ERROR" | "WARN" | "INFO" | "HINT"
+---@alias DiscussionSortBy "latest_reply" | "original_comment" | "file_line"
 
 ---@class Author
 ---@field id integer
@@ -51,6 +52,7 @@
 ---@field resolved boolean
 ---@field resolved_by Author
 ---@field resolved_at string?
+---@field internal boolean
 ---@field noteable_iid integer
 ---@field url string?
 
@@ -141,6 +143,7 @@
 ---@field commit_id string  -- This will always be ""
 ---@field line_code string
 ---@field position NotePosition
+---@field internal boolean
 ---
 ---
 --- Plugin Settings
@@ -187,6 +190,7 @@
 --- @field resolved? string
 --- @field unresolved? string
 --- @field draft? string
+--- @field internal? string
 
 ---@class CreateMrSettings: table
 ---@field target? string -- Default branch to target when creating an MR
@@ -220,6 +224,7 @@
 ---@field range? string -- The icon for lines in ranged comments, by default " |"
 
 ---@class ReviewerSettings: table
+---@field live_updates? boolean -- While reviewing, reload discussions, info and the pipeline when someone else changes the MR
 ---@field diffview? SettingsDiffview -- Settings for diffview (the dependency)
 
 ---@class SettingsDiffview: table
@@ -267,8 +272,19 @@
 ---@field unresolved? '-', -- Symbol to show next to unresolved discussions
 ---@field tree_type? string -- Type of discussion tree - "simple" means just list of discussions, "by_file_name" means file tree with discussions under file
 ---@field draft_mode? boolean -- Whether comments are posted as drafts as part of a review
+---@field sort_by? DiscussionSortBy -- How the discussion tree is sorted, cycled with toggle_sort_method
+---@field filter? DiscussionFilter -- Only fetch the discussions that match
 ---@field winbar? function -- Custom function to return winbar title, should return a string. Provided with WinbarTable (defined in annotations.lua)
 
+---@class DiscussionFilter: table
+---@field state? "all" | "resolved" | "unresolved"
+---@field authors? string[] -- Usernames of who started the discussion
+---@field path? string -- Glob for the file, ** crosses directories
+---@field created_after? string -- ISO 8601 date
+---@field created_before? string -- ISO 8601 date
+---@field mentions_me? boolean -- A note mentions the current user
+---@field text? string -- Case-insensitive search in note bodies
+
 ---@class ExpanderOpts: table<string string>
 ---@field expanded? string -- Icon for expanded discussion thread
 ---@field collapsed? string -- Icon for collapsed discussion thread
@@ -56,6 +56,8 @@ M.sequence = function(dependencies, cb)
       end
     end
 
+    state.forget_mr_if_branch_changed()
+
     -- If go server is already running, then start fetching the values in sequence
     if state.go_server_running then
       handler:fetch(dependencies, 1, argTable)
@@ -0,0 +1,112 @@
+-- This module subscribes to the Go server's stream of merge request events while the reviewer
+-- is open, and reloads whatever a colleague changed so the views stay up to date
+local Job = require("plenary.job")
+local job = require("gitlab.job")
+local u = require("gitlab.utils")
+local state = require("gitlab.state")
+
+local M = {
+  stream = nil,
+  pending = {},
+}
+
+-- What to reload for each type of event
+local reloads = {
+  note_created = "discussions",
+  note_edited = "discussions",
+  discussion_resolved = "discussions",
+  discussion_unresolved = "discussions",
+  approval_added = "info",
+  approval_revoked = "info",
+  title_changed = "info",
+  description_changed = "info",
+  pipeline_status_changed = "pipeline",
+}
+
+-- Reloads everything the last batch of events touched, once per batch rather than once per event
+local reload = function()
+  local pending = M.pending
+  M.pending = {}
+
+  if pending.discussions then
+    require("gitlab.actions.discussions").rebuild_view(false, true)
+  end
+  if pending.info then
+    state.load_new_state("info")
+  end
+  if pending.pipeline then
+    state.load_new_state("latest_pipeline")
+  end
+end
+
+---Handles one line of the Server-Sent Events stream. Only the data lines are needed, since they repeat the event type
+---@param line string
+local handle_line = function(line)
+  local encoded = line:match("^data: (.*)$")
+  if encoded == nil then
+    return
+  end
+
+  local ok, event = pcall(vim.json.decode, encoded)
+  if not ok or type(event) ~= "table" then
+    return
+  end
+
+  if event.type == "error" then
+    u.notify(string.format("Could not check the merge request for changes: %s", event.message), vim.log.levels.WARN)
+    return
+  end
+
+  local target = reloads[event.type]
+  if target == nil then
+    return
+  end
+
+  if next(M.pending) == nil then
+    vim.defer_fn(reload, 100)
+  end
+  M.pending[target] = true
+end
+
+-- Starts streaming events for the current merge request, unless a stream is already open
+M.start = function()
+  if M.stream ~= nil or not state.settings.reviewer_settings.live_updates then
+    return
+  end
+
+  local args, writer = job.build_args("/events", "GET")
+  table.insert(args, 1, "-N") -- Do not buffer the stream
+
+  M.stream = Job:new({
+    command = "curl",
+    args = args,
+    writer = writer,
+    on_stdout = function(_, line)
+      vim.schedule(function()
+        handle_line(line)
+      end)
+    end,
+    on_exit = function(j)
+      vim.schedule(function()
+        if M.stream == j then
+          M.stream = nil
+        end
+      end)
+    end,
+  })
+  M.stream:start()
+end
+
+-- Stops streaming events, the server stops polling once nobody is subscribed
+M.stop = function()
+  if M.stream == nil then
+    return
+  end
+
+  local stream = M.stream
+  M.stream = nil
+  M.pending = {}
+  stream:shutdown()
+end
+
+return M
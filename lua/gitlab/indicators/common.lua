@@ -13,11 +13,17 @@ local M = {}
 
 ---@param note NoteWithValues
 ---@param file string
+---@param status table|nil Where the server placed the discussion in the latest version
 ---@return boolean
-local filter_discussions_and_notes = function(note, file)
+local filter_discussions_and_notes = function(note, file, status)
   ---Do not include unlinked notes
   return note.position ~= nil
-    and (note.position.new_path == file or note.position.old_path == file)
+    and (
+      note.position.new_path == file
+      or note.position.old_path == file
+      ---Keep discussions whose file was renamed since they were left
+      or (status ~= nil and status.file_name == file)
+    )
     ---Skip resolved discussions if user wants to
     and not (state.settings.discussion_signs.skip_resolved_discussion and note.resolvable and note.resolved)
     ---Skip discussions from old revisions
@@ -48,7 +54,12 @@ M.filter_placeable_discussions = function()
 
   local filtered_discussions = List.new(discussions):filter(function(discussion)
     local first_note = discussion.notes[1]
-    return type(first_note.position) == "table" and filter_discussions_and_notes(first_note, file)
+    local status = M.get_position_status(discussion)
+    ---Skip discussions whose lines no longer exist in the latest version
+    if status ~= nil and status.outdated then
+      return false
+    end
+    return type(first_note.position) == "table" and filter_discussions_and_notes(first_note, file, status)
   end)
 
   local filtered_draft_notes = List.new(draft_notes):filter(function(note)
@@ -98,4 +109,26 @@ M.get_first_note = function(d_or_n)
   return d_or_n.notes and d_or_n.notes[1] or d_or_n
 end
 
+---Where the discussion sits on the latest version of the diff, as worked out by the server. Draft notes
+---and discussions left on the latest version have no status or are marked as current.
+---@param d_or_n Discussion|DraftNote
+---@return table|nil
+M.get_position_status = function(d_or_n)
+  local positions = state.DISCUSSION_DATA and state.DISCUSSION_DATA.positions or {}
+  return positions[d_or_n.id]
+end
+
+---Returns the old and new line of the discussion, following it to the latest version if it has moved
+---@param d_or_n Discussion|DraftNote
+---@return integer|nil old_line
+---@return integer|nil new_line
+M.get_current_lines = function(d_or_n)
+  local position = M.get_first_note(d_or_n).position
+  local status = M.get_position_status(d_or_n)
+  if status ~= nil and status.moved then
+    return status.old_line, status.new_line
+  end
+  return position.old_line, position.new_line
+end
+
 return M
@@ -72,9 +72,10 @@ local create_multiline_diagnostic = function(d_or_n)
     error("Parsing multi-line comment but note does not contain line range")
   end
 
+  local old_line, new_line = indicators_common.get_current_lines(d_or_n)
   local start_line, end_line, _ = actions_common.get_line_numbers_for_range(
-    first_note.position.old_line,
-    first_note.position.new_line,
+    old_line,
+    new_line,
     line_range.start.line_code,
     line_range["end"].line_code
   )
@@ -17,6 +17,7 @@ local create_mr = require("gitlab.actions.create_mr")
 local approvals = require("gitlab.actions.approvals")
 local draft_notes = require("gitlab.actions.draft_notes")
 local labels = require("gitlab.actions.labels")
+local miscellaneous = require("gitlab.actions.miscellaneous")
 local health = require("gitlab.health")
 
 local user = state.dependencies.user
@@ -78,6 +79,7 @@ return {
   end,
   pipeline = async.sequence({ latest_pipeline }, pipeline.open),
   merge = async.sequence({ u.merge(info, { refresh = true }) }, merge.merge),
+  export = async.sequence({ info }, miscellaneous.export),
   -- Discussion Tree Actions 🌴
   toggle_discussions = function()
     if discussions.split_visible then
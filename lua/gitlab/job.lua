@@ -4,9 +4,44 @@ local Job = require("plenary.job")
 local u = require("gitlab.utils")
 local M = {}
 
-M.run_job = function(endpoint, method, body, callback)
+---Whether the endpoint acts on a merge request, and so should be sent the chosen merge request's IID
+---@param endpoint string
+---@return boolean
+local is_mr_endpoint = function(endpoint)
+  local path = endpoint:match("^[^?]*")
+  return vim.startswith(path, "/mr/") or path == "/events" or path == "/pipeline"
+end
+
+---Builds the curl arguments for a request to the Go server. The secret is returned separately, to be
+---written to curl's stdin
+---@param endpoint string
+---@param method string|nil
+---@return string[], string|nil
+M.build_args = function(endpoint, method)
   local state = require("gitlab.state")
+  if state.mr_iid ~= nil and state.mr_iid ~= 0 and is_mr_endpoint(endpoint) then
+    local separator = endpoint:find("?", 1, true) and "&" or "?"
+    endpoint = string.format("%s%smr_iid=%d", endpoint, separator, state.mr_iid)
+  end
+
   local args = { "-s", "-X", (method or "POST"), string.format("localhost:%s", state.settings.port) .. endpoint }
+  if state.settings.socket_path ~= nil then
+    args = { "-s", "-X", (method or "POST"), "--unix-socket", state.settings.socket_path, "http://localhost" .. endpoint }
+  end
+
+  -- The secret is read from stdin rather than passed as an argument so that it does not show up in `ps`
+  local writer = nil
+  if state.server_secret ~= nil then
+    table.insert(args, 1, "-H")
+    table.insert(args, 2, "@-")
+    writer = "X-Gitlab-Nvim-Secret: " .. state.server_secret
+  end
+
+  return args, writer
+end
+
+M.run_job = function(endpoint, method, body, callback)
+  local args, writer = M.build_args(endpoint, method)
 
   if body ~= nil then
     local encoded_body = vim.json.encode(body)
@@ -21,6 +56,7 @@ M.run_job = function(endpoint, method, body, callback)
   Job:new({
     command = "curl",
     args = args,
+    writer = writer,
     on_stdout = function(_, output)
       vim.defer_fn(function()
         if output == nil then
@@ -63,6 +63,7 @@ M.open = function()
 
   M.is_open = true
   M.tabnr = vim.api.nvim_get_current_tabpage()
+  require("gitlab.events").start()
 
   if state.settings.discussion_diagnostic ~= nil or state.settings.discussion_sign ~= nil then
     u.notify(
@@ -75,6 +76,7 @@ M.open = function()
   local on_diffview_closed = function(view)
     if view.tabpage == M.tabnr then
       M.tabnr = nil
+      require("gitlab.events").stop()
     end
   end
   require("diffview.config").user_emitter:on("view_closed", function(_, ...)
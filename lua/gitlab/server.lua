@@ -16,15 +16,18 @@ M.start = function(callback)
   local go_server_settings = {
     gitlab_url = state.settings.gitlab_url,
     port = port,
-    auth_token = state.settings.auth_token,
+    socket_path = state.settings.socket_path,
+    auth_token_file = state.settings.auth_token_file,
+    auth_type = state.settings.auth_type,
+    oauth_client_id = state.settings.oauth_client_id,
+    oauth_token_file = state.settings.oauth_token_file
+      or (state.settings.auth_type == "oauth" and vim.fn.stdpath("state") .. "/gitlab.nvim/oauth_token.json" or nil),
     debug = state.settings.debug,
     log_path = state.settings.log_path,
     connection_settings = state.settings.connection_settings,
-    chosen_mr_iid = state.chosen_mr_iid,
+    chosen_mr_iid = state.mr_iid or 0,
   }
 
-  state.chosen_mr_iid = 0 -- Do not let this interfere with subsequent reviewer.open() calls
-
   local settings = vim.json.encode(go_server_settings)
   if vim.fn.has("win32") then
     settings = settings:gsub('"', '\\"')
@@ -32,15 +35,27 @@ M.start = function(callback)
 
   local command = string.format('"%s" "%s"', state.settings.bin, settings)
 
+  -- The token is handed to the server through its environment rather than its arguments,
+  -- which are visible to every user on the machine
+  local env = nil
+  if state.settings.auth_token ~= nil then
+    env = { GITLAB_TOKEN = state.settings.auth_token }
+  end
+
   local job_id = vim.fn.jobstart(command, {
+    env = env,
     on_stdout = function(_, data)
-      -- if port was not provided then we need to parse it from output of server
+      -- if port was not provided then we need to parse it (and the session secret) from output of server
       if parsed_port == nil then
         for _, line in ipairs(data) do
           port = line:match("Server started on port:%s+(%d+)")
-          if port ~= nil then
-            parsed_port = port
-            state.settings.port = port
+          local socket = line:match("Server started on socket:%s+(%S+)")
+          if port ~= nil or socket ~= nil then
+            parsed_port = port or socket
+            if port ~= nil then
+              state.settings.port = port
+            end
+            state.server_secret = line:match("with secret:%s+(%x+)")
             break
           end
         end
@@ -116,6 +131,8 @@ M.shutdown = function(cb)
   end
   job.run_job("/shutdown", "POST", { restart = false }, function(data)
     state.go_server_running = false
+    state.mr_iid = nil
+    state.mr_branch = nil
     state.clear_data()
     if cb then
       cb()
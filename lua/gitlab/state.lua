@@ -46,17 +46,24 @@ M.settings = {
   auth_provider = M.default_auth_provider,
   file_separator = u.path_separator,
   port = nil, -- choose random port
+  socket_path = nil, -- listen on a Unix socket at this path instead of a TCP port
+  auth_token_file = nil, -- read the Gitlab token from this file if no token is found in the environment
+  auth_type = "private", -- "private", "oauth" or "job"
+  oauth_client_id = nil, -- the OAuth application used to refresh expired OAuth tokens
+  oauth_token_file = nil, -- where refreshed OAuth tokens are kept, defaults to a file in stdpath("state")
   debug = {
     request = false,
     response = false,
     gitlab_request = false,
     gitlab_response = false,
+    auth = false,
   },
   log_path = (vim.fn.stdpath("cache") .. "/gitlab.nvim.log"),
   config_path = nil,
   reviewer = "diffview",
   reviewer_settings = {
     jump_with_no_diagnostics = false,
+    live_updates = true,
     diffview = {
       imply_local = false,
     },
@@ -64,6 +71,13 @@ M.settings = {
   connection_settings = {
     insecure = false,
     remote = "origin",
+    ca_file = nil,
+    client_cert_file = nil,
+    client_key_file = nil,
+    proxy = nil,
+    retries = 0,
+    retry_wait_min_ms = 500,
+    retry_wait_max_ms = 10000,
   },
   attachment_dir = "",
   keymaps = {
@@ -159,6 +173,7 @@ M.settings = {
     default_view = "discussions",
     blacklist = {},
     sort_by = "latest_reply",
+    filter = nil,
     keep_current_open = false,
     position = "bottom",
     size = "20%",
@@ -167,6 +182,7 @@ M.settings = {
     unresolved = "-",
     unlinked = "󰌸",
     draft = "✎",
+    internal = "",
     tree_type = "simple",
     draft_mode = false,
   },
@@ -266,8 +282,14 @@ M.unlinked_discussion_tree = {
   unresolved_expanded = false,
 }
 
--- Used to set a specific MR when choosing a merge request
-M.chosen_mr_iid = 0
+-- The merge request chosen for review, and the branch it was chosen on. It is sent with every request
+-- for a merge request so the server does not need to be restarted to switch merge requests, when nil
+-- the server uses the merge request of the current branch
+M.mr_iid = nil
+M.mr_branch = nil
+
+-- The per-session secret printed by the Go server on startup, sent back with every request
+M.server_secret = nil
 
 -- These keymaps are set globally when the plugin is initialized
 M.set_global_keymaps = function()
@@ -424,17 +446,11 @@ M.setPluginConfiguration = function()
     return
   end
 
+  -- A missing token is not an error here, the Go server also looks for one in the
+  -- configured token file, git's credential helpers, and the glab CLI config
   M.settings.auth_token = token
   M.settings.gitlab_url = u.trim_slash(url or "https://gitlab.com")
 
-  if M.settings.auth_token == nil then
-    vim.notify(
-      "Missing authentication token for Gitlab, please provide it as an environment variable or in the .gitlab.nvim file",
-      vim.log.levels.ERROR
-    )
-    return false
-  end
-
   M.initialized = true
   return true
 end
@@ -528,9 +544,15 @@ M.dependencies = {
     refresh = false,
     method = "POST",
     body = function()
+      -- An empty table would be encoded as a JSON array, which the server cannot read as a filter
+      local filter = M.settings.discussion_tree.filter
+      if filter ~= nil and vim.tbl_isempty(filter) then
+        filter = nil
+      end
       return {
         blacklist = M.settings.discussion_tree.blacklist,
         sort_by = M.settings.discussion_tree.sort_by,
+        filter = filter,
       }
     end,
   },
@@ -554,11 +576,28 @@ M.load_new_state = function(dep, cb)
   )
 end
 
+-- Forgets the chosen merge request once another branch is checked out, so that it does not
+-- outlive the review it was chosen for
+M.forget_mr_if_branch_changed = function()
+  if M.mr_iid == nil then
+    return
+  end
+
+  local branch, err = require("gitlab.git").get_current_branch()
+  if err ~= nil or branch == M.mr_branch then
+    return
+  end
+
+  M.mr_iid = nil
+  M.mr_branch = nil
+  M.clear_data()
+end
+
 -- This function clears out all of the previously fetched data. It's used
 -- to reset the plugin state when the Go server is restarted
 M.clear_data = function()
   M.INFO = nil
-  for _, dep in ipairs(M.dependencies) do
+  for _, dep in pairs(M.dependencies) do
     M[dep.state] = nil
   end
 end